@@ -1,6 +1,10 @@
 package codegen
 
-import "github.com/gluax-lang/gluax/frontend/ast"
+import (
+	"strings"
+
+	"github.com/gluax-lang/gluax/frontend/ast"
+)
 
 type BlockFlag uint8
 
@@ -14,7 +18,8 @@ const (
 func (cg *Codegen) genBlockX(b *ast.Block, flags BlockFlag) string {
 	toReturn := "nil"
 
-	if len(b.Stmts) == 0 {
+	stmts := b.ActiveStmts()
+	if len(stmts) == 0 {
 		return toReturn
 	}
 
@@ -23,7 +28,8 @@ func (cg *Codegen) genBlockX(b *ast.Block, flags BlockFlag) string {
 		cg.pushIndent()
 	}
 
-	for i, stmt := range b.Stmts {
+	cg.pushDeferFrame()
+	for i, stmt := range stmts {
 		val, isValue := cg.genStmt(stmt)
 		if isValue {
 			toReturn = val
@@ -32,6 +38,7 @@ func (cg *Codegen) genBlockX(b *ast.Block, flags BlockFlag) string {
 			break
 		}
 	}
+	defers := cg.popDeferFrame()
 
 	if flags&BlockDropValue != 0 {
 		if !isNoOp(toReturn) {
@@ -39,6 +46,18 @@ func (cg *Codegen) genBlockX(b *ast.Block, flags BlockFlag) string {
 		}
 	}
 
+	// exits by return/break/continue/throw already ran the defers
+	if len(defers) > 0 && !b.Type().IsUnreachable() {
+		if flags&BlockDropValue == 0 && toReturn != "nil" {
+			count := 1
+			if ty := b.Type(); ty.IsTuple() {
+				count = len(ty.Tuple().Elems)
+			}
+			toReturn = strings.Join(cg.storeInTemps(toReturn, count), ", ")
+		}
+		cg.genDeferFrame(defers)
+	}
+
 	if flags&BlockWrap != 0 {
 		cg.popIndent()
 		cg.ln("end")
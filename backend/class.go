@@ -10,14 +10,11 @@ import (
 )
 
 func (cg *Codegen) decorateClassName_internal(cls *ast.SemClass) string {
-	if !cg.markUsed(cls) {
-		cg.generateClass(cls)
-	}
 	var sb strings.Builder
 	sb.WriteString(frontend.CLASS_PREFIX)
 	sb.WriteString(cls.Def.Name.Raw)
-	sb.WriteString(fmt.Sprintf("_%d", cls.Def.Span().ID))
-	sb.WriteString(fmt.Sprintf("_%p", cls))
+	sb.WriteString(cg.stableID(cls.Def.Span()))
+	sb.WriteString(fmt.Sprintf("_%d", classInstance(cls)))
 	return sb.String()
 }
 
@@ -33,10 +30,6 @@ func classHeaders(cg *Codegen) {
 
 }
 
-func (cg *Codegen) classFuncUsedName(clss *ast.SemClass, methodName string) string {
-	return fmt.Sprintf("%s.%s", cg.decorateClassName_internal(clss), methodName)
-}
-
 func (cg *Codegen) generateClass(st *ast.SemClass) {
 	if !st.IsFullyConcrete() {
 		return // we don't generate classes with generics, because they will never be used
@@ -61,6 +54,10 @@ func (cg *Codegen) generateClass(st *ast.SemClass) {
 			return
 		}
 	}
+	if st.Def.IsNewtype() {
+		cg.genNewtypeFuncs(st, methods)
+		return
+	}
 	name := cg.decorateClassName(st)
 	{
 		if _, ok := cg.generatedClasses[name]; ok {
@@ -73,7 +70,7 @@ func (cg *Codegen) generateClass(st *ast.SemClass) {
 	cg.genClassFuncs(st, methods)
 	cg.popIndent()
 	cg.ln("};")
-	if !st.Attributes().Has("no__index", "no_metatable") && !st.IsGlobal() {
+	if !st.Attributes().Has("no__index", "no_metatable") && !st.IsGlobal() && !st.Def.IsNewtype() {
 		cg.ln("%s.__index = %s;", name, name)
 		if st.Super != nil {
 			superName := cg.decorateClassName(st.Super)
@@ -83,11 +80,12 @@ func (cg *Codegen) generateClass(st *ast.SemClass) {
 }
 
 func (cg *Codegen) genClassFuncs(clss *ast.SemClass, funcs map[string]*sema.SemFunction) {
-	for name, method := range funcs {
+	for _, name := range sortedMethodNames(funcs) {
+		method := funcs[name]
 		if method.Def.Body == nil {
 			continue
 		}
-		if !cg.isMarkedUsed(cg.classFuncUsedName(clss, name)) {
+		if !cg.isReachable(sema.MethodRef{Class: clss, Name: name}) {
 			continue
 		}
 		// we need to handle it with body, to make sure body calls are generated correctly
@@ -99,6 +97,22 @@ func (cg *Codegen) genClassFuncs(clss *ast.SemClass, funcs map[string]*sema.SemF
 	}
 }
 
+// genNewtypeFuncs generates the methods of a newtype, newtypes are erased so
+// there is no class table to put them in.
+func (cg *Codegen) genNewtypeFuncs(clss *ast.SemClass, funcs map[string]*sema.SemFunction) {
+	for _, name := range sortedMethodNames(funcs) {
+		method := funcs[name]
+		if method.Def.Body == nil {
+			continue
+		}
+		if !cg.isReachable(sema.MethodRef{Class: clss, Name: name}) {
+			continue
+		}
+		hMethod := cg.Analysis.HandleClassMethod(clss, method, true)
+		cg.ln("%s = %s;", cg.decorateFuncName(hMethod), cg.genFunction(hMethod))
+	}
+}
+
 func getClassFieldIndex(clss *ast.SemClass, fieldName string) string {
 	if clss.Attributes().Has("named_fields") {
 		return fmt.Sprintf("[%q]", fieldName)
@@ -131,6 +145,9 @@ func (cg *Codegen) genClassInit(si *ast.ExprClassInit, st *ast.SemClass) string
 }
 
 func (cg *Codegen) genDotAccess(expr *ast.DotAccess, toIndex string, toIndexTy ast.SemType) string {
+	if expr.Method != nil {
+		return cg.genMethodRef(expr, toIndex, toIndexTy)
+	}
 	st := toIndexTy.Class()
 	// Use numeric index for field access
 	return fmt.Sprintf("%s%s", toIndex, getClassFieldIndex(st, expr.Name.Raw))
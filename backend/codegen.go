@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/gluax-lang/gluax/common"
 	"github.com/gluax-lang/gluax/frontend"
 	"github.com/gluax-lang/gluax/frontend/ast"
 	"github.com/gluax-lang/gluax/frontend/lexer"
@@ -29,6 +30,12 @@ type funcScope struct {
 	errorVar    string   // variable to hold the error value (if any)
 	returnVars  []string // variables to return from this function
 	usedLabel   bool     // whether the return label has been used in the function
+	returnCount int      // number of values returned, used to hold them while defers run
+	deferDepth  int      // defer frames at or above this depth belong to this function
+
+	inlinedLocals int // locals added by calls inlined into this function
+
+	unchecked bool // `#[unchecked]`, no runtime checks in debug builds
 }
 
 type Codegen struct {
@@ -44,6 +51,8 @@ type Codegen struct {
 
 	loopLblStack []loopLabel
 
+	deferStack []deferFrame
+
 	publicIndex int            // next index for public symbols
 	publicMap   map[string]int // from symbol's "raw" name -> integer index
 
@@ -53,11 +62,27 @@ type Codegen struct {
 
 	funcScopeStack []*funcScope
 
-	usedPublics  map[any]struct{} // set of used public symbols
-	checkingUsed bool
+	reach    *sema.Reachability // what to generate in release builds, nil generates everything
+	inlining *sema.InlinePolicy
+	// functions that are called without being inlined after all, while reach
+	// only expected inlined calls of them
+	lateFuncs []lateFunc
+
+	files        map[string]*sema.Analysis // files of the state being generated
+	boundMethods map[sema.FuncRef]struct{} // methods of the state used as bound values
+	sourceLines  map[string][]string       // lines of files, for messages of runtime checks
+	chunkLocals  map[string]struct{}       // locals declared by the headers
+	errors       []Error
+
+	curSpan   common.Span   // span the next emitted line maps to
+	spanDirty bool          // whether curSpan changed since the last emitted marker
+	spans     []common.Span // spans referenced by the markers in the output
 }
 
-type loopLabel struct{ cont, brk string }
+type loopLabel struct {
+	cont, brk  string
+	deferDepth int // defer frames at or above this depth belong to the loop body
+}
 
 func (cg *Codegen) setAnalysis(analysis *Analysis) {
 	cg.Analysis = analysis
@@ -114,6 +139,7 @@ func (cg *Codegen) ln(format string, args ...any) {
 		cg.writeByte('\n')
 		return
 	}
+	cg.writeSpanMark()
 	cg.writeIndent()
 	cg.writef(format, args...)
 	cg.writeByte('\n')
@@ -222,15 +248,9 @@ func (cg *Codegen) collectTemps() func() {
 	}
 }
 
-func (cg *Codegen) emitTempLocals() {
-	vars := cg.popTempScope()
-	if len(vars) > 0 {
-		cg.ln("local %s;", strings.Join(vars, ", "))
-	}
-}
-
 // Push a new function scope onto the stack
 func (cg *Codegen) pushFuncScope(scope *funcScope) {
+	scope.deferDepth = len(cg.deferStack)
 	cg.funcScopeStack = append(cg.funcScopeStack, scope)
 }
 
@@ -250,10 +270,25 @@ func (cg *Codegen) currentFuncScope() *funcScope {
 	return cg.funcScopeStack[len(cg.funcScopeStack)-1]
 }
 
+// inlinedLocals returns the counter of locals added by inlining to the lua
+// function being generated, inlined bodies don't get a function of their own.
+// It is nil for top level code.
+func (cg *Codegen) inlinedLocals() *int {
+	for i := len(cg.funcScopeStack) - 1; i >= 0; i-- {
+		if scope := cg.funcScopeStack[i]; !scope.inlining {
+			return &scope.inlinedLocals
+		}
+	}
+	return nil
+}
+
 func (cg *Codegen) generateClasses() {
 	for _, st := range cg.Ast.Classes {
+		if !st.Cfg.Active() {
+			continue
+		}
 		for _, inst := range st.GetClassStack() {
-			if !cg.canGenerate(inst.Type) {
+			if !cg.isReachable(inst.Type) {
 				continue
 			}
 			cg.generateClass(inst.Type)
@@ -265,6 +300,9 @@ func (cg *Codegen) generateClasses() {
 func (cg *Codegen) generateTraitImpls() {
 	generated := make(map[*ast.SemTrait]struct{}, len(cg.Ast.ImplTraits))
 	for _, tImpl := range cg.Ast.ImplTraits {
+		if !tImpl.Cfg.Active() {
+			continue
+		}
 		trait := tImpl.ResolvedTrait
 		if _, exists := generated[trait]; exists {
 			continue // already generated this trait implementation
@@ -276,29 +314,69 @@ func (cg *Codegen) generateTraitImpls() {
 
 func (cg *Codegen) generateFunctions() {
 	for _, funDef := range cg.Ast.Funcs {
-		if funDef.IsGlobal() {
+		if !funDef.Cfg.Active() || funDef.IsGlobal() {
 			continue
 		}
 		fun := funDef.Sem()
 		name := cg.decorateFuncName(fun)
-		if !cg.canGenerate(fun) {
+		if !cg.isReachable(sema.FuncRefOf(fun)) {
 			continue
 		}
+		restoreSpan := cg.setSpan(funDef.Span())
 		cg.ln("%s = %s;", name, cg.genFunction(fun))
+		restoreSpan()
 		cg.ln("")
 	}
 }
 
 func (cg *Codegen) generateLets() {
 	for _, let := range cg.Ast.Lets {
-		if !cg.canGenerate(let) {
+		if !let.Cfg.Active() || !cg.isReachable(let) || isFoldedConst(let) {
 			continue
 		}
+		restoreSpan := cg.setSpan(let.Span())
 		cg.genLet(let)
+		restoreSpan()
 		cg.ln("")
 	}
 }
 
+// generateRoots exposes `#[export]` items as globals and registers
+// `#[hook = "Event"]` functions, once everything they use is defined.
+func (cg *Codegen) generateRoots() {
+	for _, let := range cg.Ast.Lets {
+		if !let.Cfg.Active() || !let.Attributes.Has("export") || let.IsConst || !cg.isReachable(let) {
+			continue
+		}
+		for i, name := range let.Names {
+			global := name.Raw
+			if rename := let.Attributes.GetString("export"); rename != nil {
+				global = *rename
+			}
+			cg.ln("%s = %s;", global, cg.decorateLetName(let, i))
+		}
+	}
+	for _, funDef := range cg.Ast.Funcs {
+		if !funDef.Cfg.Active() || funDef.Body == nil || funDef.Name == nil || !cg.isReachable(sema.FuncRefOf(funDef.Sem())) {
+			continue // left out when the roots don't include it
+		}
+		restoreSpan := cg.setSpan(funDef.Span())
+		name := cg.decorateFuncName(funDef.Sem())
+		if funDef.Attributes.Has("export") {
+			global := funDef.Name.Raw
+			if rename := funDef.Attributes.GetString("export"); rename != nil {
+				global = *rename
+			}
+			cg.ln("%s = %s;", global, name)
+		}
+		if event := funDef.Attributes.GetString("hook"); event != nil {
+			id := cg.ProjectAnalysis.CurrentPackage() + "." + funDef.Name.Raw
+			cg.ln("hook.Add(%s, %s, %s);", ast.QuoteLua(*event), ast.QuoteLua(id), cg.guardCallback(name))
+		}
+		restoreSpan()
+	}
+}
+
 // check if "s" is a no-op expression
 func isNoOp(s string) bool {
 	if lexer.IsValidIdent(s) {
@@ -0,0 +1,66 @@
+package codegen
+
+import (
+	"strings"
+
+	"github.com/gluax-lang/gluax/frontend/ast"
+)
+
+// every generated block gets a frame, defers are registered in the frame of
+// the block they appear in, in source order
+type deferFrame []*ast.StmtDefer
+
+func (cg *Codegen) pushDeferFrame() {
+	cg.deferStack = append(cg.deferStack, nil)
+}
+
+func (cg *Codegen) popDeferFrame() deferFrame {
+	if len(cg.deferStack) == 0 {
+		panic("codegen: popDeferFrame underflow")
+	}
+	frame := cg.deferStack[len(cg.deferStack)-1]
+	cg.deferStack = cg.deferStack[:len(cg.deferStack)-1]
+	return frame
+}
+
+func (cg *Codegen) genStmtDefer(stmt *ast.StmtDefer) {
+	// nothing is emitted here, the body is generated at every exit of the block
+	top := len(cg.deferStack) - 1
+	cg.deferStack[top] = append(cg.deferStack[top], stmt)
+}
+
+// hasDefersFrom reports whether any frame at or above depth has registered defers.
+func (cg *Codegen) hasDefersFrom(depth int) bool {
+	for _, frame := range cg.deferStack[depth:] {
+		if len(frame) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// genDefersFrom emits the defers of every frame at or above depth, innermost first
+// and in reverse registration order, used when jumping out of those blocks.
+func (cg *Codegen) genDefersFrom(depth int) {
+	for i := len(cg.deferStack) - 1; i >= depth; i-- {
+		cg.genDeferFrame(cg.deferStack[i])
+	}
+}
+
+func (cg *Codegen) genDeferFrame(frame deferFrame) {
+	for i := len(frame) - 1; i >= 0; i-- {
+		cg.ln("--[[defer]]")
+		cg.genBlockX(&frame[i].Body, BlockWrap|BlockDropValue)
+	}
+}
+
+// storeInTemps evaluates values into count fresh temps, so that deferred code
+// can't observe or change them before they are used.
+func (cg *Codegen) storeInTemps(values string, count int) []string {
+	temps := make([]string, count)
+	for i := range temps {
+		temps[i] = cg.getTempVar()
+	}
+	cg.ln("%s = %s;", strings.Join(temps, ", "), values)
+	return temps
+}
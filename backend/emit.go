@@ -0,0 +1,539 @@
+package codegen
+
+import (
+	"slices"
+	"strings"
+)
+
+// the emit modes work on the generated text, codegen itself always writes
+// pretty code. Lines are never joined, so the source map and the runtime error
+// rewriting keep working on compact and minified output.
+
+type luaTokKind uint8
+
+const (
+	luaTokSpace luaTokKind = iota
+	luaTokNewline
+	luaTokComment
+	luaTokMarker // span marker, see sourcemap.go
+	luaTokName
+	luaTokKeyword
+	luaTokNumber
+	luaTokString
+	luaTokSymbol
+)
+
+type luaTok struct {
+	kind luaTokKind
+	text string
+}
+
+var luaKeywords = map[string]struct{}{
+	"and": {}, "break": {}, "do": {}, "else": {}, "elseif": {}, "end": {},
+	"false": {}, "for": {}, "function": {}, "goto": {}, "if": {}, "in": {},
+	"local": {}, "nil": {}, "not": {}, "or": {}, "repeat": {}, "return": {},
+	"then": {}, "true": {}, "until": {}, "while": {},
+}
+
+func isLuaNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isLuaDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLuaNameChar(c byte) bool {
+	return isLuaNameStart(c) || isLuaDigit(c)
+}
+
+// longBracketLevel returns the level of a long bracket opening at s[i], or -1.
+func longBracketLevel(s string, i int) int {
+	if i >= len(s) || s[i] != '[' {
+		return -1
+	}
+	j := i + 1
+	for j < len(s) && s[j] == '=' {
+		j++
+	}
+	if j < len(s) && s[j] == '[' {
+		return j - i - 1
+	}
+	return -1
+}
+
+// skipLongBracket returns the index after the long bracket opening at s[i].
+func skipLongBracket(s string, i, level int) int {
+	closing := "]" + strings.Repeat("=", level) + "]"
+	if end := strings.Index(s[i+level+2:], closing); end >= 0 {
+		return i + level + 2 + end + len(closing)
+	}
+	return len(s)
+}
+
+// isExponent reports whether number ends with an exponent marker, which can
+// be followed by a sign.
+func isExponent(number string) bool {
+	last := number[len(number)-1]
+	if strings.HasPrefix(number, "0x") || strings.HasPrefix(number, "0X") {
+		return last == 'p' || last == 'P'
+	}
+	return last == 'e' || last == 'E'
+}
+
+func tokenizeLua(src string) []luaTok {
+	var toks []luaTok
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		start := i
+		kind := luaTokSymbol
+		switch {
+		case c == '\n':
+			i++
+			kind = luaTokNewline
+		case c == ' ' || c == '\t' || c == '\r':
+			for i < len(src) && (src[i] == ' ' || src[i] == '\t' || src[i] == '\r') {
+				i++
+			}
+			kind = luaTokSpace
+		case c == spanMarker:
+			if end := strings.IndexByte(src[i+1:], spanMarker); end >= 0 {
+				i += end + 2
+			} else {
+				i = len(src)
+			}
+			kind = luaTokMarker
+		case c == '-' && i+1 < len(src) && src[i+1] == '-':
+			if level := longBracketLevel(src, i+2); level >= 0 {
+				i = skipLongBracket(src, i+2, level)
+			} else if end := strings.IndexByte(src[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(src)
+			}
+			kind = luaTokComment
+		case c == '"' || c == '\'':
+			i++
+			for i < len(src) && src[i] != c {
+				if src[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			i = min(i+1, len(src))
+			kind = luaTokString
+		case longBracketLevel(src, i) >= 0:
+			i = skipLongBracket(src, i, longBracketLevel(src, i))
+			kind = luaTokString
+		case isLuaDigit(c) || (c == '.' && i+1 < len(src) && isLuaDigit(src[i+1])):
+			for i < len(src) && (isLuaNameChar(src[i]) || src[i] == '.' ||
+				((src[i] == '+' || src[i] == '-') && isExponent(src[start:i]))) {
+				i++
+			}
+			kind = luaTokNumber
+		case isLuaNameStart(c):
+			for i < len(src) && isLuaNameChar(src[i]) {
+				i++
+			}
+			kind = luaTokName
+			if _, ok := luaKeywords[src[start:i]]; ok {
+				kind = luaTokKeyword
+			}
+		default:
+			i++
+			for _, op := range []string{"...", "..", "==", "~=", "<=", ">=", "::"} {
+				if strings.HasPrefix(src[start:], op) {
+					i = start + len(op)
+					break
+				}
+			}
+		}
+		toks = append(toks, luaTok{kind: kind, text: src[start:i]})
+	}
+	return toks
+}
+
+// compactCode strips comments, indentation and blank lines. It runs before the
+// span markers are resolved, markers of dropped lines move to the next line.
+func compactCode(code string) string {
+	var sb strings.Builder
+	sb.Grow(len(code))
+	var line, carried strings.Builder
+	empty, space := true, false
+	flush := func() {
+		if empty {
+			return
+		}
+		sb.WriteString(carried.String())
+		sb.WriteString(line.String())
+		sb.WriteByte('\n')
+		carried.Reset()
+	}
+	for _, tok := range tokenizeLua(code) {
+		switch tok.kind {
+		case luaTokNewline:
+			flush()
+			line.Reset()
+			empty, space = true, false
+		case luaTokSpace, luaTokComment:
+			space = !empty
+		case luaTokMarker:
+			if empty {
+				carried.WriteString(tok.text)
+			} else {
+				line.WriteString(tok.text)
+			}
+		default:
+			if space {
+				line.WriteByte(' ')
+			}
+			line.WriteString(tok.text)
+			empty, space = false, false
+		}
+	}
+	flush()
+	return sb.String()
+}
+
+// minifyCode renames locals and labels to short names and drops the spaces
+// lua doesn't need, lines are kept as they are.
+func minifyCode(code string) string {
+	toks := tokenizeLua(code)
+	renames := shortLocalNames(toks)
+
+	var sb strings.Builder
+	sb.Grow(len(code))
+	var prev luaTok // last written token of the line, markers don't count
+	for i, tok := range toks {
+		switch tok.kind {
+		case luaTokSpace, luaTokComment:
+			continue
+		case luaTokNewline:
+			sb.WriteByte('\n')
+			prev = luaTok{}
+			continue
+		case luaTokMarker:
+			sb.WriteString(tok.text)
+			continue
+		}
+		if short, ok := renames[i]; ok {
+			tok.text = short
+		}
+		if prev.text != "" && needsSpace(prev, tok) {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(tok.text)
+		prev = tok
+	}
+	return sb.String()
+}
+
+// needsSpace reports whether writing b right after a would lex differently.
+func needsSpace(a, b luaTok) bool {
+	x, y := a.text[len(a.text)-1], b.text[0]
+	switch {
+	case isLuaNameChar(x) && isLuaNameChar(y):
+		return true
+	case a.kind == luaTokNumber && (y == '.' || isLuaNameChar(y)):
+		return true
+	case x == '.' && (y == '.' || isLuaDigit(y)):
+		return true
+	case x == '-' && y == '-':
+		return true
+	case x == '[' && (y == '[' || y == '='):
+		return true
+	}
+	return false
+}
+
+type luaBlock struct {
+	kind   byte // 'b' for blocks with a scope, or the opening bracket
+	scopes int  // scopes that were open before the block
+}
+
+// localScanner resolves every name of a chunk to either a local or a global.
+// It only needs to be right about that distinction: names are renamed as a
+// whole, so a name is renamed only if none of its uses is a global.
+type localScanner struct {
+	toks    []luaTok
+	scopes  []map[string]struct{}
+	blocks  []luaBlock
+	uses    map[string][]int // token indexes of locals and their uses
+	labels  map[string][]int
+	globals map[string]struct{}
+
+	pending      []int // names of a `local` statement, declared once it ends
+	pendingDepth int
+	forVars      []int // loop variables, declared by the `do` of the loop
+}
+
+func (s *localScanner) next(i int) int {
+	for i++; i < len(s.toks); i++ {
+		switch s.toks[i].kind {
+		case luaTokSpace, luaTokComment, luaTokMarker:
+		case luaTokNewline:
+			if s.pending == nil {
+				continue
+			}
+			return i
+		default:
+			return i
+		}
+	}
+	return len(s.toks)
+}
+
+func (s *localScanner) is(i int, text string) bool {
+	return i < len(s.toks) && s.toks[i].kind != luaTokString && s.toks[i].text == text
+}
+
+func (s *localScanner) isName(i int) bool {
+	return i < len(s.toks) && s.toks[i].kind == luaTokName
+}
+
+func (s *localScanner) declare(idx int) {
+	name := s.toks[idx].text
+	s.scopes[len(s.scopes)-1][name] = struct{}{}
+	s.uses[name] = append(s.uses[name], idx)
+}
+
+func (s *localScanner) use(idx int) {
+	name := s.toks[idx].text
+	for j := len(s.scopes) - 1; j >= 0; j-- {
+		if _, ok := s.scopes[j][name]; ok {
+			s.uses[name] = append(s.uses[name], idx)
+			return
+		}
+	}
+	s.globals[name] = struct{}{}
+}
+
+func (s *localScanner) push(kind byte) {
+	s.blocks = append(s.blocks, luaBlock{kind: kind, scopes: len(s.scopes)})
+	if kind == 'b' {
+		s.scopes = append(s.scopes, make(map[string]struct{}))
+	}
+}
+
+func (s *localScanner) pop() {
+	if len(s.blocks) == 0 {
+		return
+	}
+	b := s.blocks[len(s.blocks)-1]
+	s.blocks = s.blocks[:len(s.blocks)-1]
+	s.scopes = s.scopes[:b.scopes]
+}
+
+func (s *localScanner) flushPending() {
+	for _, idx := range s.pending {
+		s.declare(idx)
+	}
+	s.pending = nil
+}
+
+// names reads a `name, name, ...` list starting at i.
+func (s *localScanner) names(i int) ([]int, int) {
+	var idxs []int
+	for s.isName(i) {
+		idxs = append(idxs, i)
+		i = s.next(i)
+		if !s.is(i, ",") {
+			break
+		}
+		i = s.next(i)
+	}
+	return idxs, i
+}
+
+// function scans a function header starting after `function`, local is the
+// index of the name of a `local function`.
+func (s *localScanner) function(i int, local int) int {
+	if local >= 0 {
+		s.declare(local)
+		i = s.next(local)
+	} else if s.isName(i) {
+		s.use(i)
+		i = s.next(i)
+	}
+	method := false
+	for s.is(i, ".") || s.is(i, ":") {
+		method = method || s.is(i, ":")
+		i = s.next(s.next(i))
+	}
+	s.push('b')
+	if method {
+		// the implicit `self` has no token to rename, so it's never renamed
+		s.scopes[len(s.scopes)-1]["self"] = struct{}{}
+		s.globals["self"] = struct{}{}
+	}
+	if !s.is(i, "(") {
+		return i
+	}
+	params, i := s.names(s.next(i))
+	for _, idx := range params {
+		s.declare(idx)
+	}
+	if s.is(i, "...") {
+		i = s.next(i)
+	}
+	if s.is(i, ")") {
+		i = s.next(i)
+	}
+	return i
+}
+
+func (s *localScanner) scan() {
+	s.scopes = []map[string]struct{}{make(map[string]struct{})}
+	prev := -1
+	i := s.next(-1)
+	for i < len(s.toks) {
+		tok := s.toks[i]
+		if s.pending != nil && len(s.blocks) == s.pendingDepth {
+			switch {
+			case tok.kind == luaTokNewline, s.is(i, ";"):
+				s.flushPending()
+			case tok.kind == luaTokKeyword && tok.text != "function" && tok.text != "nil" &&
+				tok.text != "true" && tok.text != "false" && tok.text != "not" &&
+				tok.text != "and" && tok.text != "or":
+				s.flushPending()
+			}
+		}
+		if tok.kind == luaTokNewline {
+			i = s.next(i)
+			continue
+		}
+		cur := i
+		i = s.next(i)
+		switch {
+		case tok.kind == luaTokKeyword:
+			switch tok.text {
+			case "local":
+				if s.is(i, "function") {
+					i = s.function(s.next(i), s.next(i))
+					break
+				}
+				var names []int
+				names, i = s.names(i)
+				if s.is(i, "=") {
+					s.pending, s.pendingDepth = names, len(s.blocks)
+				} else {
+					for _, idx := range names {
+						s.declare(idx)
+					}
+				}
+			case "function":
+				i = s.function(i, -1)
+			case "for":
+				s.forVars, i = s.names(i)
+			case "do":
+				s.push('b')
+				for _, idx := range s.forVars {
+					s.declare(idx)
+				}
+				s.forVars = nil
+			case "then", "repeat":
+				s.push('b')
+			case "elseif", "end", "until":
+				s.pop()
+			case "else":
+				s.pop()
+				s.push('b')
+			case "goto":
+				if s.isName(i) {
+					s.labels[s.toks[i].text] = append(s.labels[s.toks[i].text], i)
+					i = s.next(i)
+				}
+			}
+		case tok.kind == luaTokSymbol:
+			switch tok.text {
+			case "(", "[", "{":
+				s.push(tok.text[0])
+			case ")", "]", "}":
+				s.pop()
+			case "::":
+				if s.isName(i) {
+					s.labels[s.toks[i].text] = append(s.labels[s.toks[i].text], i)
+					i = s.next(s.next(i))
+				}
+			}
+		case tok.kind == luaTokName:
+			switch {
+			case prev >= 0 && (s.is(prev, ".") || s.is(prev, ":")):
+				// field
+			case s.is(i, "=") && len(s.blocks) > 0 && s.blocks[len(s.blocks)-1].kind == '{' &&
+				(s.is(prev, "{") || s.is(prev, ",") || s.is(prev, ";")):
+				// key of a table constructor
+			default:
+				s.use(cur)
+			}
+		}
+		prev = cur
+	}
+}
+
+// shortLocalNames maps the token indexes of renamed locals and labels to their
+// new names, the most used names get the shortest ones.
+func shortLocalNames(toks []luaTok) map[int]string {
+	s := &localScanner{
+		toks:    toks,
+		uses:    make(map[string][]int),
+		labels:  make(map[string][]int),
+		globals: make(map[string]struct{}),
+	}
+	s.scan()
+
+	locals := make(map[string][]int)
+	for name, idxs := range s.uses {
+		if _, ok := s.globals[name]; !ok {
+			locals[name] = idxs
+		}
+	}
+	renames := make(map[int]string)
+	assignShortNames(renames, locals, s.globals)
+	// labels live in their own namespace
+	assignShortNames(renames, s.labels, nil)
+	return renames
+}
+
+func assignShortNames(renames map[int]string, names map[string][]int, taken map[string]struct{}) {
+	order := make([]string, 0, len(names))
+	for name := range names {
+		order = append(order, name)
+	}
+	slices.SortFunc(order, func(a, b string) int {
+		if d := len(names[b]) - len(names[a]); d != 0 {
+			return d
+		}
+		return names[a][0] - names[b][0]
+	})
+	next := shortNames(taken)
+	for _, name := range order {
+		short := next()
+		for _, idx := range names[name] {
+			renames[idx] = short
+		}
+	}
+}
+
+// shortNames returns a generator of the names a, b, ..., _, aa, ab, ...
+// skipping keywords and taken names.
+func shortNames(taken map[string]struct{}) func() string {
+	const first = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ_"
+	const rest = first + "0123456789"
+	n := 0
+	return func() string {
+		for {
+			name := []byte{first[n%len(first)]}
+			for v := n / len(first); v > 0; v = (v - 1) / len(rest) {
+				name = append(name, rest[(v-1)%len(rest)])
+			}
+			n++
+			_, keyword := luaKeywords[string(name)]
+			_, used := taken[string(name)]
+			if !keyword && !used {
+				return string(name)
+			}
+		}
+	}
+}
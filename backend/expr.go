@@ -29,6 +29,7 @@ func (cg *Codegen) tempLoop(name *ast.Ident) loopLabel {
 }
 
 func (cg *Codegen) pushLoop(ll loopLabel) { // push innermost
+	ll.deferDepth = len(cg.deferStack)
 	cg.loopLblStack = append(cg.loopLblStack, ll)
 }
 
@@ -43,6 +44,15 @@ func (cg *Codegen) innermostLoop() loopLabel {
 	panic("no loop labels, should not happen")
 }
 
+func (cg *Codegen) loopByLabel(label string) loopLabel {
+	for i := len(cg.loopLblStack) - 1; i >= 0; i-- {
+		if ll := cg.loopLblStack[i]; ll.cont == label || ll.brk == label {
+			return ll
+		}
+	}
+	panic("loop label not found, should not happen")
+}
+
 func (cg *Codegen) genExprsToStrings(exprs []ast.Expr) []string {
 	if len(exprs) == 0 {
 		return nil
@@ -90,6 +100,12 @@ func (cg *Codegen) genExpr(e ast.Expr) string {
 }
 
 func (cg *Codegen) genExprX(e ast.Expr) string {
+	if e.Const != nil {
+		switch e.Kind() {
+		case ast.ExprKindPath, ast.ExprKindUnary, ast.ExprKindBinary:
+			return genConstExpr(e)
+		}
+	}
 	switch e.Kind() {
 	case ast.ExprKindNil:
 		return "nil"
@@ -98,7 +114,7 @@ func (cg *Codegen) genExprX(e ast.Expr) string {
 	case ast.ExprKindNumber:
 		return e.Number().Raw
 	case ast.ExprKindString:
-		return strconv.Quote(e.String().Raw)
+		return ast.QuoteLua(e.String().Raw)
 	case ast.ExprKindVararg:
 		return "..."
 	case ast.ExprKindPath:
@@ -153,20 +169,16 @@ func (cg *Codegen) genExprX(e ast.Expr) string {
 	}
 }
 
-func isConstPrimitive(let *ast.Let, n int) bool {
-	if let == nil || !let.IsConst {
-		return false
+// genConstExpr inlines an expression that sema evaluated at compile time
+func genConstExpr(e ast.Expr) string {
+	value := e.Const.Lua()
+	if e.Kind() == ast.ExprKindPath {
+		value += fmt.Sprintf(" --[[%s]]", e.Path().String())
 	}
-	if len(let.Names) != len(let.Values) {
-		return false
-	}
-	expr := let.Values[n]
-	switch expr.Kind() {
-	case ast.ExprKindNil, ast.ExprKindBool, ast.ExprKindNumber, ast.ExprKindString:
-		return true
-	default:
-		return false
+	if e.AsCond {
+		return "(" + value + " ~= nil)"
 	}
+	return value
 }
 
 func (cg *Codegen) genPathExpr(path *ast.Path) string {
@@ -179,17 +191,15 @@ func (cg *Codegen) genPathExpr(path *ast.Path) string {
 		if len(path.Segments) > 1 {
 			suffix = fmt.Sprintf(" --[[%s]]", path.String())
 		}
-		// this is a quick optimization for constant variables
-		if isConstPrimitive(v.Def, v.N) {
-			expr := v.Def.Values[v.N]
-			return cg.genExpr(expr) + suffix
-		}
 		return cg.decorateLetName(v.Def, v.N) + suffix
 	case ast.ValParameter:
 		p := val.Parameter()
 		return p.Def.Name.Raw
 	case ast.ValFunction:
 		v := val.Function()
+		if mayRaise(v) {
+			return cg.genRaisingFuncValue(v)
+		}
 		suffix := ""
 		if len(path.Segments) > 1 {
 			suffix = fmt.Sprintf(" --[[%s]]", path.String())
@@ -223,6 +233,8 @@ func (cg *Codegen) genBinaryExpr(binE *ast.ExprBinary) string {
 		return fmt.Sprintf("bit_lshift(%s,%s)", lhs, rhs)
 	case ast.BinaryOpBitwiseRightShift:
 		return fmt.Sprintf("bit_rshift(%s,%s)", lhs, rhs)
+	case ast.BinaryOpIntDiv:
+		return fmt.Sprintf("math_floor(%s/%s)", lhs, rhs)
 	case ast.BinaryOpLogicalOr:
 		panic("unreachable")
 	case ast.BinaryOpLogicalAnd:
@@ -313,6 +325,10 @@ func (cg *Codegen) genIfExpr(i *ast.ExprIf, outputTy ast.SemType) string {
 }
 
 func (cg *Codegen) genPostfixExpr(p *ast.ExprPostfix) string {
+	if call, ok := p.Op.(*ast.Call); ok && call.Raises && call.Method == nil {
+		// called directly, so skip the wrapper a raising function value gets
+		return cg.genCall(call, cg.decorateFuncName(call.SemaFunc), p.Left.Type())
+	}
 	value := cg.genExpr(p.Left)
 	primaryTy := p.Left.Type()
 	switch op := p.Op.(type) {
@@ -330,16 +346,7 @@ func (cg *Codegen) genPostfixExpr(p *ast.ExprPostfix) string {
 		cg.ln("end")
 		return temp
 	case *ast.UnwrapNilable:
-		// TODO: handle unwrapping nil value in DEBUG mode
-		return value
-		temp := cg.getTempVar()
-		cg.ln("%s = %s;", temp, value)
-		cg.ln("if %s == nil then", temp)
-		cg.pushIndent()
-		cg.ln("error(\"unwrapping nil value\");")
-		cg.popIndent()
-		cg.ln("end")
-		return temp
+		return cg.genUnwrapNilable(p, value)
 	default:
 		panic("unreachable; unhandled postfix operator")
 	}
@@ -6,41 +6,41 @@ import (
 
 	"github.com/gluax-lang/gluax/frontend"
 	"github.com/gluax-lang/gluax/frontend/ast"
+	"github.com/gluax-lang/gluax/frontend/sema"
 )
 
 func (cg *Codegen) decorateFuncName(f *ast.SemFunction) string {
-	if !cg.markUsed(f) {
-		cg.genFunction(f)
-	}
 	if f.IsGlobal() {
 		return f.GlobalName()
 	}
 	if f.Def.Name == nil {
 		var sb strings.Builder
 		sb.WriteString(frontend.FUNC_PREFIX)
-		sb.WriteString(fmt.Sprintf("_%d", f.Def.Span().ID))
+		sb.WriteString(cg.stableID(f.Def.Span()))
 		return sb.String()
 	}
 	raw := f.Def.Name.Raw
 	if f.Trait != nil {
-		dTName := cg.decorateTraitName(f.Trait.Def, f.Class)
+		dTName := cg.decorateTraitName(f.Trait, f.Class)
 		return dTName + "." + raw
 	}
 	if f.Class != nil {
-		stName := cg.decorateClassName(f.Class)
-		if !cg.markUsed(cg.classFuncUsedName(f.Class, raw)) {
-			cg.generateClass(f.Class)
-		}
 		if rename := f.Attributes().GetString("rename_to"); rename != nil {
 			raw = *rename
 		}
+		if f.Class.Def.IsNewtype() {
+			// newtypes have no class table, their methods are items of their own
+			baseName := cg.decorateClassName_internal(f.Class) + "." + raw
+			return cg.getPublic(baseName) + fmt.Sprintf(" --[[%s]]", f.String())
+		}
+		stName := cg.decorateClassName(f.Class)
 		return stName + "." + raw
 	}
 	var sb strings.Builder
 	sb.WriteString(frontend.FUNC_PREFIX)
 	sb.WriteString(raw)
 	if f.Def.IsItem {
-		id := fmt.Sprintf("_%d", f.Def.Span().ID)
+		id := cg.stableID(f.Def.Span())
 		sb.WriteString(id)
 	}
 	baseName := sb.String()
@@ -58,6 +58,32 @@ func (cg *Codegen) genFunctionParams(f ast.Function) []string {
 	return params
 }
 
+// canBeCalledFromLua reports whether a function can end up being called by plain
+// lua code, which knows nothing about default values, so the function itself
+// has to fill them in.
+func (cg *Codegen) canBeCalledFromLua(f *ast.SemFunction) bool {
+	if f.Def.Name == nil {
+		return true // anonymous functions are mostly handed out as callbacks
+	}
+	if f.Def.Attributes.Has("export", "hook") {
+		return true
+	}
+	if _, ok := cg.boundMethods[sema.FuncRefOf(f)]; ok {
+		return true // bound values forward their arguments as is
+	}
+	return f.Class != nil && f.Class.IsGlobal()
+}
+
+func (cg *Codegen) genDefaultsPrologue(f ast.Function) {
+	for _, p := range f.Params {
+		if p.Default == nil {
+			continue
+		}
+		name := p.Name.Raw
+		cg.ln("if %s == nil then %s = %s; end", name, name, cg.genExpr(*p.Default))
+	}
+}
+
 func (cg *Codegen) genFunction(f *ast.SemFunction) string {
 	if f.IsGlobal() {
 		return f.GlobalName()
@@ -67,8 +93,10 @@ func (cg *Codegen) genFunction(f *ast.SemFunction) string {
 	}
 	def := f.Def
 	oldBuf := cg.newBuf()
+	defer cg.setSpan(def.Span())()
 
 	// Generate function signature
+	cg.writeSpanMark()
 	cg.writeString("function(")
 	cg.writeString(strings.Join(cg.genFunctionParams(f.Def), ", "))
 	cg.writeByte(')')
@@ -77,11 +105,22 @@ func (cg *Codegen) genFunction(f *ast.SemFunction) string {
 
 	// Setup scopes for function body
 	cg.pushTempScope()
-	cg.pushFuncScope(&funcScope{})
+	scope := &funcScope{unchecked: def.Attributes.Has("unchecked")}
+	if parent := cg.currentFuncScope(); parent != nil && def.Name == nil {
+		scope.unchecked = scope.unchecked || parent.unchecked // lambdas follow their function
+	}
+	if !f.HasVarargReturn() {
+		scope.returnCount = f.ReturnCount()
+	}
+	cg.pushFuncScope(scope)
 
 	// Prepare buffer for function body
 	bodyBuf := cg.newBuf()
 
+	if f.Def.HasDefaultParams() && cg.canBeCalledFromLua(f) {
+		cg.genDefaultsPrologue(def)
+	}
+
 	// Generate function body and return statement
 	if f.HasVarargReturn() {
 		cg.genBlockX(def.Body, BlockNone)
@@ -96,9 +135,15 @@ func (cg *Codegen) genFunction(f *ast.SemFunction) string {
 
 	cg.popFuncScope()
 
+	what := "anonymous function"
+	if def.Name != nil {
+		what = fmt.Sprintf("function `%s`", def.Name.Raw)
+	}
+
 	// Emit locals and body
 	bodySnippet := cg.restoreBuf(bodyBuf)
-	cg.emitTempLocals()
+	reserved := maxActiveLocals(&def) + scope.inlinedLocals
+	bodySnippet = cg.emitLimitedTempLocals(bodySnippet, reserved, what, def.Span())
 	cg.writeString(bodySnippet)
 
 	// Close function
@@ -107,11 +152,70 @@ func (cg *Codegen) genFunction(f *ast.SemFunction) string {
 	cg.writeString("end")
 
 	// Restore and return the generated function code
-	return cg.restoreBuf(oldBuf)
+	code := cg.restoreBuf(oldBuf)
+	cg.checkUpvalues(code, capturedVars(&def), what, def.Span())
+	return code
+}
+
+// genCallArgs generates the arguments of a call in parameter order, filling in
+// named arguments and default values.
+func (cg *Codegen) genCallArgs(call *ast.Call) string {
+	// lua functions marked `#[engine_callbacks]` hand the callbacks passed to
+	// them to the engine, which calls them later outside of the guarded entry
+	guard := cg.ProjectAnalysis.Options.RewriteErrors && call.SemaFunc != nil && call.SemaFunc.Def.Body == nil &&
+		call.SemaFunc.Def.Attributes.Has("engine_callbacks")
+	if call.ArgSlots == nil {
+		if !guard {
+			return cg.genExprsLeftToRight(call.Args)
+		}
+		values := cg.genExprsToStrings(call.Args)
+		cg.guardCallbackArgs(call.Args, values)
+		return strings.Join(values, ", ")
+	}
+	// evaluate in source order, then place them in their slots
+	values := cg.genExprsToStrings(call.AllArgs())
+	if guard {
+		cg.guardCallbackArgs(call.AllArgs(), values)
+	}
+	parts := make([]string, len(call.ArgSlots))
+	for i, slot := range call.ArgSlots {
+		switch {
+		case slot.Arg != -1:
+			parts[i] = values[slot.Arg]
+		case slot.Default != nil:
+			parts[i] = cg.genExpr(*slot.Default)
+		default:
+			parts[i] = "nil"
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// guardCallbackArgs wraps the function values passed to a lua function.
+func (cg *Codegen) guardCallbackArgs(args []ast.Expr, values []string) {
+	for i, arg := range args {
+		if arg.Type().IsFunction() && !isLuaFuncPath(arg) {
+			values[i] = cg.guardCallback(values[i])
+		}
+	}
+}
+
+// isLuaFuncPath checks if e names a function without a body, passing those
+// along as is keeps lua functions that depend on the call stack working.
+func isLuaFuncPath(e ast.Expr) bool {
+	if e.Kind() != ast.ExprKindPath {
+		return false
+	}
+	val := e.Path().ResolvedSymbol.Value()
+	if val.Kind() != ast.ValFunction {
+		return false
+	}
+	fun := val.Function()
+	return fun.Def.Body == nil && !mayRaise(fun)
 }
 
 func (cg *Codegen) getCallArgs(call *ast.Call, toCall string) string {
-	args := cg.genExprsLeftToRight(call.Args)
+	args := cg.genCallArgs(call)
 	if call.Method != nil {
 		if args == "" {
 			return toCall
@@ -156,6 +260,7 @@ func (cg *Codegen) genInlineCall(call *ast.Call, fun ast.SemFunction, toCall str
 		returnLabel: returnLabel,
 		returnVars:  returnLocals,
 		errorVar:    errTemp,
+		unchecked:   fun.Def.Attributes.Has("unchecked"),
 	}
 
 	cg.pushFuncScope(&funcScope)
@@ -182,37 +287,63 @@ func (cg *Codegen) buildMethodCall(call *ast.Call, fun *ast.SemFunction, toCall
 	case toCallTy.IsClass():
 		return cg.buildClassMethodCall(call, fun, toCall, toCallTy)
 	default:
-		args := cg.genExprsLeftToRight(call.Args)
+		args := cg.genCallArgs(call)
 		return fmt.Sprintf("%s(%s)", toCall, args)
 	}
 }
 
-func (cg *Codegen) buildClassMethodCall(call *ast.Call, fun *ast.SemFunction, toCall string, toCallTy ast.SemType) string {
-	// Check if we need to use function-style call instead of method-style
-	needsFunctionCall := fun.Trait != nil ||
+// needsFunctionCall checks if we need to use function-style call instead of method-style
+func needsFunctionCall(fun *ast.SemFunction, toCallTy ast.SemType) bool {
+	return fun.Trait != nil ||
 		toCallTy.Class().Attributes().Has("no_metatable", "no__index") ||
+		// newtypes are erased, the value has the metatable of the underlying type
+		toCallTy.Class().Def.IsNewtype() ||
 		// If the class is global and method is not, then we call the method as a function
 		// as we can't use method-style call on global classes
 		// because the method won't actually exist inside it
 		(toCallTy.Class().IsGlobal() && fun.Attributes().Has("local_method"))
+}
 
-	if needsFunctionCall {
+func methodName(fun *ast.SemFunction) string {
+	if rename := fun.Attributes().GetString("rename_to"); rename != nil {
+		return *rename
+	}
+	return fun.Def.Name.Raw
+}
+
+func (cg *Codegen) buildClassMethodCall(call *ast.Call, fun *ast.SemFunction, toCall string, toCallTy ast.SemType) string {
+	if needsFunctionCall(fun, toCallTy) {
 		args := cg.getCallArgs(call, toCall)
 		return fmt.Sprintf("%s(%s)", cg.decorateFuncName(fun), args)
 	}
 
 	// Use method-style call
-	args := cg.genExprsLeftToRight(call.Args)
+	args := cg.genCallArgs(call)
+	return fmt.Sprintf("%s:%s(%s)", toCall, methodName(fun), args)
+}
 
-	var methodName string
-	if rename := fun.Attributes().GetString("rename_to"); rename != nil {
-		methodName = *rename
+// genMethodRef generates a closure for `obj.method`, `self` is passed through
+// a wrapping function so every evaluation captures its own receiver.
+func (cg *Codegen) genMethodRef(expr *ast.DotAccess, toIndex string, toIndexTy ast.SemType) string {
+	fun := expr.Method
+	// inline functions end up generated here, which is the wrapper we need
+	funcName := cg.decorateFuncName(fun)
+	var callee string
+	if mayRaise(fun) {
+		callee = cg.genProtectedCall(fun, funcName, "self, ...")
+	} else if !toIndexTy.IsClass() || needsFunctionCall(fun, toIndexTy) {
+		callee = fmt.Sprintf("%s(self, ...)", funcName)
 	} else {
-		methodName = fun.Def.Name.Raw
+		callee = fmt.Sprintf("self:%s(...)", methodName(fun))
 	}
-	return fmt.Sprintf("%s:%s(%s)", toCall, methodName, args)
+	return fmt.Sprintf("(function(self) return function(...) return %s; end; end)(%s)", callee, toIndex)
 }
 
+// inlineLocalBudget is how many locals inlined calls may add to a single lua
+// function, LuaJIT allows 200 and the function needs room for its own. Inlined
+// bodies only add locals, what they capture is already captured by the caller.
+const inlineLocalBudget = 120
+
 func (cg *Codegen) genCall(call *ast.Call, toCall string, toCallTy ast.SemType) string {
 	fun := call.SemaFunc
 
@@ -225,25 +356,29 @@ func (cg *Codegen) genCall(call *ast.Call, toCall string, toCallTy ast.SemType)
 		if !cg.ProjectAnalysis.Options.Release {
 			return false // in debug mode, we don't inline functions
 		}
-		if fun.HasVarargParam() || fun.HasVarargReturn() {
+		if !cg.inlining.Decision(fun).Inline {
 			return false
 		}
-		if fun.IsGlobal() {
-			return false
+		// the params and locals of the body become locals of the caller, the
+		// return values and error get a temp each
+		counter := cg.inlinedLocals()
+		if counter == nil {
+			return false // top level code runs once, no point in inlining it
 		}
-		if fun.Def.Body == nil {
-			return false
-		}
-		if !fun.Def.Attributes.Has("inline") {
+		locals := cg.inlining.Locals(fun) + fun.ReturnCount() + 1
+		if *counter+locals > inlineLocalBudget {
+			cg.inlining.Skip(fun, call.Span(), fmt.Sprintf("the caller already has %d locals from inlined calls", *counter))
+			cg.requireGenerated(fun)
 			return false
 		}
+		*counter += locals
 		return true
 	}
 
 	buildCallExpr := func() string {
 		// Handle non-method calls
 		if call.Method == nil {
-			args := cg.genExprsLeftToRight(call.Args)
+			args := cg.genCallArgs(call)
 			return fmt.Sprintf("%s(%s)", toCall, args)
 		}
 
@@ -263,7 +398,11 @@ func (cg *Codegen) genCall(call *ast.Call, toCall string, toCallTy ast.SemType)
 		cg.ln("if %s ~= nil then", errorTemp)
 		cg.pushIndent()
 		if call.IsTryCall {
-			cg.ln("return %s;", errorTemp)
+			errValue := errorTemp
+			if call.ErrorConv != nil {
+				errValue = fmt.Sprintf("%s(%s)", cg.decorateFuncName(call.ErrorConv), errorTemp)
+			}
+			cg.genPropagateError(errValue)
 		} else {
 			catch := call.Catch
 			cg.ln("local %s = %s;", catch.Name.Raw, errorTemp)
@@ -280,6 +419,8 @@ func (cg *Codegen) genCall(call *ast.Call, toCall string, toCallTy ast.SemType)
 	var callExpr string
 	if canInline() {
 		callExpr = cg.genInlineCall(call, *fun, toCall)
+	} else if call.Raises {
+		callExpr = cg.buildRaisingCall(call, fun, toCall, toCallTy)
 	} else {
 		cg.decorateFuncName(fun)
 		callExpr = buildCallExpr()
@@ -291,3 +432,37 @@ func (cg *Codegen) genCall(call *ast.Call, toCall string, toCallTy ast.SemType)
 
 	return handleErrorable(callExpr)
 }
+
+type lateFunc struct {
+	fun      *ast.SemFunction
+	analysis *Analysis
+}
+
+// requireGenerated makes sure f gets generated, it is needed when a call of a
+// function that is otherwise always inlined could not be inlined.
+func (cg *Codegen) requireGenerated(f *ast.SemFunction) {
+	node := sema.FuncNode(f)
+	if cg.isReachable(node) {
+		return
+	}
+	cg.reach.Add(node)
+	cg.lateFuncs = append(cg.lateFuncs, lateFunc{fun: f, analysis: cg.Analysis})
+}
+
+// generateLateFuncs generates the functions required while generating the
+// others, before any top level code can call them.
+func (cg *Codegen) generateLateFuncs() {
+	for len(cg.lateFuncs) > 0 {
+		late := cg.lateFuncs[0]
+		cg.lateFuncs = cg.lateFuncs[1:]
+		cg.setAnalysis(late.analysis)
+		fun := late.fun
+		if fun.Class != nil {
+			fun = cg.Analysis.HandleClassMethod(fun.Class, fun, true)
+		}
+		restoreSpan := cg.setSpan(fun.Def.Span())
+		cg.ln("%s = %s;", cg.decorateFuncName(fun), cg.genFunction(fun))
+		restoreSpan()
+		cg.ln("")
+	}
+}
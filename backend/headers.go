@@ -1,11 +1,18 @@
 package codegen
 
-import "github.com/gluax-lang/gluax/frontend"
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gluax-lang/gluax/frontend"
+	"github.com/gluax-lang/gluax/frontend/ast"
+)
 
 func fastLocalsHeaders(cg *Codegen) {
 	cg.ln("--[[fast access locals]]")
 	cg.ln("local string, table, bit, math = string, table, bit, math;")
 	cg.ln("local bit_bor, bit_band, bit_bxor, bit_lshift, bit_rshift, bit_bnot = bit.bor, bit.band, bit.bxor, bit.lshift, bit.rshift, bit.bnot;")
+	cg.ln("local math_floor = math.floor;")
 	cg.ln("local type = type;")
 	cg.ln("local pairs = pairs;")
 	cg.ln("local tostring, tonumber = tostring, tonumber;")
@@ -13,6 +20,115 @@ func fastLocalsHeaders(cg *Codegen) {
 	cg.ln("local SERVER, CLIENT = SERVER, CLIENT;")
 }
 
+var (
+	errmapLines   = frontend.ERRMAP_PREFIX + "lines"
+	errmapSources = frontend.ERRMAP_PREFIX + "sources"
+	errmapRewrite = frontend.ERRMAP_PREFIX + "rewrite"
+	errmapHandler = frontend.ERRMAP_PREFIX + "handler"
+	errmapDepth   = frontend.ERRMAP_PREFIX + "depth"
+	errmapRethrow = frontend.ERRMAP_PREFIX + "rethrow"
+	errmapGuard   = frontend.ERRMAP_PREFIX + "guard"
+)
+
+// errorRewriteHeaders defines the runtime side of the source map, the table
+// itself is only known after the whole file is generated, so it's assigned
+// right before the entry runs, see errorRewriteTable.
+func errorRewriteHeaders(cg *Codegen) {
+	if !cg.ProjectAnalysis.Options.RewriteErrors {
+		return
+	}
+	cg.ln("--[[runtime error rewriting]]")
+	cg.ln("local %s, %s = {}, {};", errmapLines, errmapSources)
+	cg.ln("local %s = 0;", errmapDepth)
+	cg.ln("local %s;", errmapRewrite)
+	cg.ln("do")
+	cg.pushIndent()
+	// short_src may be truncated, so only the file name is matched
+	cg.ln(`local file = debug.getinfo(1, "S").source:match("([^/\\@]+)$"):gsub("[%%^%%$%%(%%)%%%%%%.%%[%%]%%*%%+%%-%%?]", "%%%%%%0");`)
+	cg.ln(`local pattern = "[^%%s:]*" .. file .. ":(%%d+)";`)
+	cg.ln("local function lookup(line)")
+	cg.pushIndent()
+	cg.ln("local lines = %s;", errmapLines)
+	cg.ln("local lo, hi, found = 1, #lines / 4, nil;")
+	cg.ln("while lo <= hi do")
+	cg.pushIndent()
+	cg.ln("local mid = math_floor((lo + hi) / 2);")
+	cg.ln("if lines[mid * 4 - 3] <= line then found, lo = mid, mid + 1; else hi = mid - 1; end")
+	cg.popIndent()
+	cg.ln("end")
+	cg.ln("if found == nil or lines[found * 4 - 2] < 1 then return nil; end")
+	cg.ln(`return %s[lines[found * 4 - 2]] .. ":" .. lines[found * 4 - 1] .. ":" .. lines[found * 4];`, errmapSources)
+	cg.popIndent()
+	cg.ln("end")
+	cg.ln("%s = function(msg)", errmapRewrite)
+	cg.pushIndent()
+	cg.ln("return (msg:gsub(pattern, function(line) return lookup(tonumber(line)); end));")
+	cg.popIndent()
+	cg.ln("end")
+	cg.popIndent()
+	cg.ln("end")
+	// only the outermost guard adds a traceback, inner ones just rewrite the
+	// message, so protected calls inside of gluax code see the plain error
+	cg.ln("local function %s(msg)", errmapHandler)
+	cg.pushIndent()
+	cg.ln("if type(msg) ~= \"string\" then return msg; end")
+	cg.ln("if %s == 1 then msg = debug.traceback(msg, 2); end", errmapDepth)
+	cg.ln("return %s(msg);", errmapRewrite)
+	cg.popIndent()
+	cg.ln("end")
+	cg.ln("local function %s(ok, ...)", errmapRethrow)
+	cg.pushIndent()
+	cg.ln("%s = %s - 1;", errmapDepth, errmapDepth)
+	cg.ln("if ok then return ...; end")
+	cg.ln("error((...), 0);")
+	cg.popIndent()
+	cg.ln("end")
+	cg.ln("local function %s(f)", errmapGuard)
+	cg.pushIndent()
+	cg.ln("return function(...)")
+	cg.pushIndent()
+	cg.ln("%s = %s + 1;", errmapDepth, errmapDepth)
+	cg.ln("return %s(xpcall(f, %s, ...));", errmapRethrow, errmapHandler)
+	cg.popIndent()
+	cg.ln("end")
+	cg.popIndent()
+	cg.ln("end")
+}
+
+// errorRewriteTable fills in the line table of the runtime error rewriting.
+func errorRewriteTable(sm SourceMap) string {
+	var sb strings.Builder
+	sb.WriteString("--[[source map]]\n")
+	sb.WriteString(errmapSources + " = {")
+	for i, src := range sm.Sources {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(ast.QuoteLua(src))
+	}
+	sb.WriteString("};\n")
+	sb.WriteString(errmapLines + " = {")
+	for i, entry := range sm.Lines {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		// lua tables are 1-based, so the source index is shifted by one
+		sb.WriteString(strconv.Itoa(entry[0]) + ", " + strconv.Itoa(entry[1]+1) + ", " +
+			strconv.Itoa(entry[2]) + ", " + strconv.Itoa(entry[3]))
+	}
+	sb.WriteString("};\n")
+	return sb.String()
+}
+
+// guardCallback wraps a function that is handed to lua code, which may call it
+// from the engine (hooks, timers, ...), so its errors get rewritten too.
+func (cg *Codegen) guardCallback(f string) string {
+	if !cg.ProjectAnalysis.Options.RewriteErrors {
+		return f
+	}
+	return errmapGuard + "(" + f + ")"
+}
+
 func publicHeaders(cg *Codegen) {
 	cg.ln("--[[public symbols]]")
 	cg.ln("local %s = {};", frontend.PUBLIC_TBL)
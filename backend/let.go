@@ -9,16 +9,13 @@ import (
 )
 
 func (cg *Codegen) decorateLetName(l *ast.Let, n int) string {
-	if !cg.markUsed(l) {
-		cg.genLet(l)
-	}
 	if l.IsGlobal() {
 		return l.GlobalName(n)
 	}
 	name := l.Names[n]
 	raw := name.Raw
 	if l.IsItem {
-		id := fmt.Sprintf("_%d", name.Span().ID)
+		id := cg.stableID(name.Span())
 		return cg.getPublic(frontend.LOCAL_PREFIX + raw + id)
 	}
 	return raw
@@ -38,8 +35,22 @@ func (cg *Codegen) genLetLHS(l *ast.Let) []string {
 	return lhs
 }
 
+// isFoldedConst reports whether every value of a const is known at compile
+// time, all uses get inlined so there is nothing to generate.
+func isFoldedConst(l *ast.Let) bool {
+	if !l.IsConst || len(l.Names) != len(l.Values) {
+		return false
+	}
+	for _, value := range l.Values {
+		if value.Const == nil {
+			return false
+		}
+	}
+	return true
+}
+
 func (cg *Codegen) genLet(l *ast.Let) {
-	if l.IsGlobal() {
+	if l.IsGlobal() || isFoldedConst(l) {
 		return
 	}
 	rhs := cg.genExprsLeftToRight(l.Values)
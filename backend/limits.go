@@ -0,0 +1,298 @@
+package codegen
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/gluax-lang/gluax/common"
+	"github.com/gluax-lang/gluax/frontend"
+	"github.com/gluax-lang/gluax/frontend/ast"
+)
+
+// LuaJIT refuses to load a chunk with a function that has more than 200 active
+// locals or 60 upvalues, and all it says is "too many local variables" with a
+// line of the generated file.
+const (
+	luaMaxLocals   = 200
+	luaMaxUpvalues = 60
+
+	// temps get spilled into a table once a function gets this close to
+	// the local limit, the margin covers locals codegen adds on its own
+	localSpillMargin = 16
+)
+
+var spillTbl = frontend.SPILL_TBL
+
+// Error is a problem found while generating code, the generated code would
+// not load.
+type Error struct {
+	Span    common.Span
+	Message string
+}
+
+func (cg *Codegen) errorf(span common.Span, format string, args ...any) {
+	err := Error{Span: span, Message: fmt.Sprintf(format, args...)}
+	if slices.Contains(cg.errors, err) {
+		return // functions can get generated more than once
+	}
+	cg.errors = append(cg.errors, err)
+}
+
+// emitLimitedTempLocals emits the temps of the current temp scope for a body
+// that already needs reserved locals, spilling the temps into a table if they
+// don't fit. It returns the body, rewritten if the temps were spilled.
+func (cg *Codegen) emitLimitedTempLocals(body string, reserved int, what string, span common.Span) string {
+	temps := cg.popTempScope()
+	if reserved+len(temps) <= luaMaxLocals-localSpillMargin {
+		if len(temps) > 0 {
+			cg.ln("local %s;", strings.Join(temps, ", "))
+		}
+		return body
+	}
+
+	if len(temps) > 0 {
+		slots := make(map[string]string, len(temps))
+		for i, temp := range temps {
+			slots[temp] = fmt.Sprintf("%s[%d]", spillTbl, i+1)
+		}
+		body = tempNameRegex.ReplaceAllStringFunc(body, func(name string) string {
+			if slot, ok := slots[name]; ok {
+				return slot
+			}
+			return name
+		})
+		cg.ln("local %s = {};", spillTbl)
+		reserved++
+	}
+	if reserved > luaMaxLocals {
+		cg.errorf(span, "%s needs about %d locals at once, LuaJIT allows %d; split it up or keep some values in a table",
+			what, reserved, luaMaxLocals)
+	}
+	return body
+}
+
+var tempNameRegex = regexp.MustCompile(regexp.QuoteMeta(strings.TrimSuffix(frontend.TEMP_PREFIX, "%d")) + `\d+`)
+
+// checkUpvalues reports a function that captures more than lua allows, code
+// is the generated function and captured the gluax variables it captures.
+func (cg *Codegen) checkUpvalues(code string, captured int, what string, span common.Span) {
+	upvalues := captured
+	for name := range referencedNames(code) {
+		if _, ok := cg.chunkLocals[name]; ok {
+			upvalues++
+		}
+	}
+	if upvalues > luaMaxUpvalues {
+		cg.errorf(span, "%s captures %d variables, LuaJIT allows %d upvalues; pass some of them in a table",
+			what, upvalues, luaMaxUpvalues)
+	}
+}
+
+// referencedNames returns the names lua code refers to as variables, leaving
+// out strings, comments, field names and keys of table constructors.
+func referencedNames(code string) map[string]struct{} {
+	var toks []luaTok
+	for _, tok := range tokenizeLua(code) {
+		switch tok.kind {
+		case luaTokSpace, luaTokNewline, luaTokComment, luaTokMarker:
+		default:
+			toks = append(toks, tok)
+		}
+	}
+	names := make(map[string]struct{})
+	var braces []bool // open brackets, true for `{`, a `name =` in those is a key
+	for i, tok := range toks {
+		switch {
+		case tok.kind == luaTokSymbol && (tok.text == "(" || tok.text == "[" || tok.text == "{"):
+			braces = append(braces, tok.text == "{")
+		case tok.kind == luaTokSymbol && (tok.text == ")" || tok.text == "]" || tok.text == "}"):
+			if len(braces) > 0 {
+				braces = braces[:len(braces)-1]
+			}
+		case tok.kind == luaTokName:
+			var prev, next string
+			if i > 0 {
+				prev = toks[i-1].text
+			}
+			if i+1 < len(toks) {
+				next = toks[i+1].text
+			}
+			if prev == "." || prev == ":" {
+				continue // field access or method call
+			}
+			inBraces := len(braces) > 0 && braces[len(braces)-1]
+			if inBraces && (prev == "{" || prev == "," || prev == ";") && next == "=" {
+				continue // key of a table constructor
+			}
+			names[tok.text] = struct{}{}
+		}
+	}
+	return names
+}
+
+// localDeclRegex matches the locals of the chunk itself, the headers indent
+// the ones of nested functions and blocks.
+var localDeclRegex = regexp.MustCompile(`(?m)^local\s+(?:function\s+([A-Za-z_][A-Za-z0-9_]*)|([A-Za-z_][A-Za-z0-9_, ]*))`)
+
+// declareChunkLocals records the locals declared at the top of the chunk,
+// functions that use them get them as upvalues.
+func (cg *Codegen) declareChunkLocals(code string) {
+	for _, m := range localDeclRegex.FindAllStringSubmatch(code, -1) {
+		names := m[1]
+		if names == "" {
+			names = m[2]
+		}
+		for name := range strings.SplitSeq(names, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				cg.chunkLocals[name] = struct{}{}
+			}
+		}
+	}
+}
+
+// capturedVars counts the distinct variables declared outside of f that its
+// body uses, including the uses of nested functions.
+func capturedVars(f *ast.Function) int {
+	if f.Body == nil {
+		return 0
+	}
+	fnSpan := f.Span()
+	seen := make(map[common.Span]struct{})
+	ast.WalkBlock(f.Body, func(node any) bool {
+		e, ok := node.(*ast.Expr)
+		if !ok || e.Kind() != ast.ExprKindPath {
+			return true
+		}
+		sym := e.Path().ResolvedSymbol
+		if sym == nil || !sym.IsValue() {
+			return true
+		}
+		val := sym.Value()
+		switch val.Kind() {
+		case ast.ValFunction:
+			return true
+		case ast.ValVariable:
+			if val.Variable().Def.IsItem {
+				return true // items live in the public table
+			}
+		}
+		if decl := val.Span(); !spanContains(fnSpan, decl) {
+			seen[decl] = struct{}{}
+		}
+		return true
+	})
+	return len(seen)
+}
+
+func spanContains(outer, inner common.Span) bool {
+	if outer.Source != inner.Source {
+		return false
+	}
+	after := inner.LineStart > outer.LineStart ||
+		(inner.LineStart == outer.LineStart && inner.ColumnStart >= outer.ColumnStart)
+	before := inner.LineStart < outer.LineEnd ||
+		(inner.LineStart == outer.LineEnd && inner.ColumnStart <= outer.ColumnEnd)
+	return after && before
+}
+
+// maxActiveLocals estimates the most locals a function keeps alive at once,
+// the limit lua enforces. Nested functions have their own.
+func maxActiveLocals(f *ast.Function) int {
+	if f.Body == nil {
+		return len(f.Params)
+	}
+	return len(f.Params) + blockMaxLocals(f.Body)
+}
+
+// hidden locals lua uses to run a for loop
+const forLoopLocals = 3
+
+func blockMaxLocals(b *ast.Block) int {
+	active, best := 0, 0
+	for _, stmt := range b.ActiveStmts() {
+		switch s := stmt.(type) {
+		case *ast.Let:
+			for i := range s.Values {
+				best = max(best, active+exprMaxLocals(&s.Values[i]))
+			}
+			active += len(s.Names)
+		case *ast.StmtExpr:
+			best = max(best, active+exprMaxLocals(&s.Expr))
+		case *ast.StmtReturn:
+			best = max(best, active+exprsMaxLocals(s.Exprs))
+		case *ast.StmtAssignment:
+			best = max(best, active+exprsMaxLocals(s.LhsExprs), active+exprsMaxLocals(s.RhsExpr))
+		case *ast.StmtThrow:
+			best = max(best, active+exprMaxLocals(&s.Value))
+		case *ast.StmtDefer:
+			// the body is generated at every exit of the block
+			best = max(best, active+blockMaxLocals(&s.Body))
+		}
+		best = max(best, active)
+	}
+	return best
+}
+
+func exprsMaxLocals(exprs []ast.Expr) int {
+	best := 0
+	for i := range exprs {
+		best = max(best, exprMaxLocals(&exprs[i]))
+	}
+	return best
+}
+
+// exprMaxLocals is how many locals evaluating e needs on top of the ones
+// already alive.
+func exprMaxLocals(e *ast.Expr) int {
+	best := 0
+	ast.WalkExpr(e, func(node any) bool {
+		ex, ok := node.(*ast.Expr)
+		if !ok {
+			return true
+		}
+		switch ex.Kind() {
+		case ast.ExprKindFunction:
+			return false
+		case ast.ExprKindBlock:
+			best = max(best, blockMaxLocals(ex.Block()))
+		case ast.ExprKindIf:
+			it := ex.If()
+			best = max(best, exprMaxLocals(&it.Main.Cond), blockMaxLocals(&it.Main.Then))
+			for i := range it.Branches {
+				best = max(best, exprMaxLocals(&it.Branches[i].Cond), blockMaxLocals(&it.Branches[i].Then))
+			}
+			if it.Else != nil {
+				best = max(best, blockMaxLocals(it.Else))
+			}
+		case ast.ExprKindWhile:
+			while := ex.While()
+			best = max(best, exprMaxLocals(&while.Cond), blockMaxLocals(&while.Body))
+		case ast.ExprKindLoop:
+			best = max(best, blockMaxLocals(&ex.Loop().Body))
+		case ast.ExprKindForNum:
+			forNum := ex.ForNum()
+			best = max(best, exprMaxLocals(&forNum.Start), exprMaxLocals(&forNum.End))
+			if forNum.Step != nil {
+				best = max(best, exprMaxLocals(forNum.Step))
+			}
+			best = max(best, forLoopLocals+1+blockMaxLocals(&forNum.Body))
+		case ast.ExprKindForIn:
+			forIn := ex.ForIn()
+			best = max(best, exprMaxLocals(&forIn.InExpr))
+			best = max(best, forLoopLocals+len(forIn.Vars)+blockMaxLocals(&forIn.Body))
+		case ast.ExprKindPostfix:
+			call, ok := ex.Postfix().Op.(*ast.Call)
+			if !ok || call.Catch == nil {
+				return true
+			}
+			best = max(best, exprMaxLocals(&ex.Postfix().Left), exprsMaxLocals(call.AllArgs()))
+			best = max(best, 1+blockMaxLocals(&call.Catch.Block))
+		default:
+			return true
+		}
+		return false
+	})
+	return best
+}
@@ -1,10 +1,12 @@
 package codegen
 
 import (
+	"fmt"
 	"regexp"
 	"sort"
 	"strings"
 
+	"github.com/gluax-lang/gluax/common"
 	"github.com/gluax-lang/gluax/frontend/sema"
 )
 
@@ -14,10 +16,26 @@ func removeRedundantBlankLines(s string) string {
 	return redundantNewlinesRegex.ReplaceAllString(s, "$1$1")
 }
 
-func GenerateProject(pA *sema.ProjectAnalysis) (string, string) {
-	serverCode := generateCode(pA, pA.ServerState())
-	// clientCode := generateCode(pA, pA.ClientState())
-	return removeRedundantBlankLines(serverCode), removeRedundantBlankLines("clientCode")
+// Output is the generated code of one realm, with its source map.
+type Output struct {
+	Code   string
+	Map    SourceMap
+	Errors []Error // the code won't load if there are any
+	// Modules are the files of split builds, Code loads them in order
+	Modules []Module
+}
+
+func GenerateProject(pA *sema.ProjectAnalysis) (Output, Output) {
+	return GenerateServer(pA), GenerateClient(pA)
+}
+
+func GenerateServer(pA *sema.ProjectAnalysis) Output {
+	return generateCode(pA, pA.ServerState())
+}
+
+func GenerateClient(pA *sema.ProjectAnalysis) Output {
+	// return generateCode(pA, pA.ClientState())
+	return Output{Code: removeRedundantBlankLines("clientCode")}
 }
 
 func newCodegen(pA *sema.ProjectAnalysis) *Codegen {
@@ -29,58 +47,68 @@ func newCodegen(pA *sema.ProjectAnalysis) *Codegen {
 		publicIndex:      1,
 		publicMap:        make(map[string]int),
 		generatedClasses: make(map[string]struct{}),
-		usedPublics:      make(map[any]struct{}),
+		sourceLines:      make(map[string][]string),
+		chunkLocals:      make(map[string]struct{}),
 	}
 	cg.buf().Grow(1024 * 2)
 	return &cg
 }
 
-func generateCode(pA *sema.ProjectAnalysis, state *sema.State) string {
+func generateCode(pA *sema.ProjectAnalysis, state *sema.State) Output {
 	cg := newCodegen(pA)
+	cg.files = state.Files
+	cg.boundMethods = state.BoundMethods
 	if pA.Options.Release {
-		cg.usedPublics = checkUsed(pA, state)
+		cg.inlining = pA.InlinePolicy(state)
+		cg.reach = pA.Reachable(state, pA.Roots())
 	}
 	headers(cg)
-	cg.handleFiles(state.Files)
-	if mainFunc := state.MainFunc; mainFunc != nil {
-		cg.ln("%s()", cg.decorateFuncName(mainFunc))
+	cg.declareChunkLocals(cg.buf().String())
+	var modules []Module
+	if pA.Options.Split {
+		modules = cg.generateModules(state)
+	} else {
+		cg.handleFiles(state.Files)
 	}
-	return cg.buf().String()
-}
-
-func checkUsed(pA *sema.ProjectAnalysis, state *sema.State) map[any]struct{} {
-	cg := newCodegen(pA)
-	cg.checkingUsed = true
-	main := state.Files[cg.ProjectAnalysis.Main]
-	cg.setAnalysis(main)
-	cg.decorateFuncName(state.MainFunc)
-	return cg.usedPublics
-}
-
-func (cg *Codegen) markUsed(v any) bool {
-	if !cg.checkingUsed {
-		return true // if we are not checking used, then act like it got used
+	var entry string
+	if mainFunc := state.MainFunc; mainFunc != nil && cg.isReachable(sema.FuncRefOf(mainFunc)) {
+		entry = fmt.Sprintf("%s()", cg.guardCallback(cg.decorateFuncName(mainFunc)))
 	}
-	_, exists := cg.usedPublics[v]
-	cg.usedPublics[v] = struct{}{} // mark it as used
-	return exists
+	if !pA.Options.RewriteErrors && entry != "" {
+		cg.ln("%s", entry)
+	}
+	out := cg.finishChunk(cg.buf().String(), entry)
+	out.Modules = modules
+	out.Errors = cg.errors
+	return out
 }
 
-func (cg *Codegen) isMarkedUsed(v any) bool {
-	if !cg.ProjectAnalysis.Options.Release {
-		return true
+// finishChunk lays out a generated lua file and builds its source map.
+func (cg *Codegen) finishChunk(code, entry string) Output {
+	pA := cg.ProjectAnalysis
+	code = removeRedundantBlankLines(code)
+	if pA.Options.Emit != sema.EmitPretty {
+		code = compactCode(code)
+	}
+	code, sm := cg.resolveSpanMarks(code)
+	if pA.Options.RewriteErrors {
+		// appended after the line table is known, lines before it keep their numbers
+		code += errorRewriteTable(sm)
+		if entry != "" {
+			code += entry + "\n"
+		}
+	}
+	if pA.Options.Emit == sema.EmitMinified {
+		// keeps lines as they are, so it can run after the line table is built
+		code = minifyCode(code)
 	}
-	_, exists := cg.usedPublics[v]
-	return exists
+	return Output{Code: code, Map: sm}
 }
 
-func (cg *Codegen) canGenerate(v any) bool {
-	if !cg.ProjectAnalysis.Options.Release || cg.checkingUsed {
-		// if we are checking unused, then we should always return true
-		return true
-	}
-	_, exists := cg.usedPublics[v]
-	return exists
+// isReachable reports whether a node of the reference graph has to be
+// generated, everything is generated outside of release builds.
+func (cg *Codegen) isReachable(node any) bool {
+	return cg.reach == nil || cg.reach.Has(node)
 }
 
 func (cg *Codegen) handleFiles(files map[string]*sema.Analysis) {
@@ -101,11 +129,15 @@ func (cg *Codegen) handleFiles(files map[string]*sema.Analysis) {
 	cg.runGenerationPhase(files, paths, func(cg *Codegen) {
 		cg.generateFunctions()
 	})
+	cg.generateLateFuncs()
 	cg.runGenerationPhase(files, paths, func(cg *Codegen) {
 		cg.generateLets()
 	})
+	cg.runGenerationPhase(files, paths, func(cg *Codegen) {
+		cg.generateRoots()
+	})
 	generated := cg.restoreBuf(oldBuf)
-	cg.emitTempLocals()
+	generated = cg.emitLimitedTempLocals(generated, len(cg.chunkLocals), "top level code", common.Span{})
 	cg.writeString(generated)
 }
 
@@ -123,6 +155,12 @@ func headers(cg *Codegen) {
 	fastLocalsHeaders(cg)
 	cg.writeByte('\n')
 
+	errorRewriteHeaders(cg)
+	cg.writeByte('\n')
+
+	raiseHeaders(cg)
+	cg.writeByte('\n')
+
 	classHeaders(cg)
 	cg.writeByte('\n')
 
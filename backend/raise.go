@@ -0,0 +1,59 @@
+package codegen
+
+import (
+	"fmt"
+
+	"github.com/gluax-lang/gluax/frontend"
+	"github.com/gluax-lang/gluax/frontend/ast"
+)
+
+// mayRaise reports whether a lua function can raise with `error()`, calls to
+// it are protected and the raised error becomes a thrown gluax error.
+func mayRaise(fun *ast.SemFunction) bool {
+	return fun.Def.Attributes.Has("may_raise")
+}
+
+// genProtectedCall calls `callee` with pcall (or xpcall with a traceback) and
+// converts the result to the errorable `(err, values...)` convention.
+func (cg *Codegen) genProtectedCall(fun *ast.SemFunction, callee, args string) string {
+	var protected string
+	if fun.Def.Attributes.HasTokenTreeArgs("may_raise", "traceback") {
+		protected = "xpcall(" + callee + ", debug.traceback"
+	} else {
+		protected = "pcall(" + callee
+	}
+	if args != "" {
+		protected += ", " + args
+	}
+	return fmt.Sprintf("%s(%s))", frontend.RAISED_FUNC, protected)
+}
+
+func (cg *Codegen) buildRaisingCall(call *ast.Call, fun *ast.SemFunction, toCall string, toCallTy ast.SemType) string {
+	switch {
+	case call.Method == nil || !toCallTy.IsClass():
+		return cg.genProtectedCall(fun, toCall, cg.genCallArgs(call))
+	case needsFunctionCall(fun, toCallTy):
+		return cg.genProtectedCall(fun, cg.decorateFuncName(fun), cg.getCallArgs(call, toCall))
+	default:
+		// the receiver is used twice, once to look up the method and once as `self`
+		receiver := cg.getTempVar()
+		cg.ln("%s = %s;", receiver, toCall)
+		return cg.genProtectedCall(fun, receiver+"."+methodName(fun), cg.getCallArgs(call, receiver))
+	}
+}
+
+// genRaisingFuncValue wraps a raising function used as a value, so calling it
+// follows the errorable convention like any other `!` function.
+func (cg *Codegen) genRaisingFuncValue(fun *ast.SemFunction) string {
+	return fmt.Sprintf("(function(...) return %s; end)", cg.genProtectedCall(fun, cg.decorateFuncName(fun), "..."))
+}
+
+func raiseHeaders(cg *Codegen) {
+	cg.ln("--[[lua errors to gluax errors]]")
+	cg.ln("local function %s(ok, ...)", frontend.RAISED_FUNC)
+	cg.pushIndent()
+	cg.ln("if ok then return nil, ...; end")
+	cg.ln("return tostring((...));")
+	cg.popIndent()
+	cg.ln("end")
+}
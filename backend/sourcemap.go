@@ -0,0 +1,136 @@
+package codegen
+
+import (
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gluax-lang/gluax/common"
+)
+
+// generated snippets get moved between buffers a lot (temp locals are emitted
+// after the body is generated, functions are generated into their own buffer),
+// so instead of tracking line numbers while generating, a marker holding the
+// index of the span is written at the start of a line and the markers are
+// resolved into a line table once the whole output is known
+const spanMarker = '\x01'
+
+// SourceMap links lines of a generated lua file back to the gluax source.
+type SourceMap struct {
+	Version int      `json:"version"`
+	File    string   `json:"file"`
+	Sources []string `json:"sources"`
+	// each entry is [lua line, source index, line, column], all 1-based and
+	// sorted by lua line. A lua line belongs to the last entry at or before it,
+	// a source index of -1 marks generated code with no source.
+	Lines [][4]int `json:"lines"`
+}
+
+// Lookup returns the source position of a 1-based lua line.
+func (m *SourceMap) Lookup(luaLine int) (source string, line, column int, ok bool) {
+	idx := sort.Search(len(m.Lines), func(i int) bool {
+		return m.Lines[i][0] > luaLine
+	}) - 1
+	if idx < 0 {
+		return "", 0, 0, false
+	}
+	entry := m.Lines[idx]
+	if entry[1] < 0 || entry[1] >= len(m.Sources) {
+		return "", 0, 0, false
+	}
+	return m.Sources[entry[1]], entry[2], entry[3], true
+}
+
+// setSpan makes the following lines map to span, the returned function
+// restores the previous span.
+func (cg *Codegen) setSpan(span common.Span) func() {
+	old := cg.curSpan
+	cg.curSpan = span
+	cg.spanDirty = true
+	return func() {
+		cg.curSpan = old
+		cg.spanDirty = true
+	}
+}
+
+func (cg *Codegen) writeSpanMark() {
+	if !cg.spanDirty {
+		return
+	}
+	cg.spanDirty = false
+	cg.writeByte(spanMarker)
+	cg.writeString(strconv.Itoa(len(cg.spans)))
+	cg.writeByte(spanMarker)
+	cg.spans = append(cg.spans, cg.curSpan)
+}
+
+// resolveSpanMarks strips the span markers from code and builds the line table.
+func (cg *Codegen) resolveSpanMarks(code string) (string, SourceMap) {
+	sm := SourceMap{Version: 1, Sources: []string{}, Lines: [][4]int{}}
+	sourceIdx := make(map[string]int)
+
+	var sb strings.Builder
+	sb.Grow(len(code))
+
+	lines := strings.Split(code, "\n")
+	for i, line := range lines {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		mark := -1
+		for {
+			start := strings.IndexByte(line, spanMarker)
+			if start < 0 {
+				break
+			}
+			end := strings.IndexByte(line[start+1:], spanMarker)
+			if end < 0 {
+				break
+			}
+			end += start + 1
+			if n, err := strconv.Atoi(line[start+1 : end]); err == nil {
+				mark = n // the last one wins, it is the innermost
+			}
+			line = line[:start] + line[end+1:]
+		}
+		sb.WriteString(line)
+
+		if mark < 0 || mark >= len(cg.spans) {
+			continue
+		}
+		span := cg.spans[mark]
+		entry := [4]int{i + 1, -1, 0, 0}
+		if span.Source != "" {
+			src := cg.sourceMapPath(span.Source)
+			idx, exists := sourceIdx[src]
+			if !exists {
+				idx = len(sm.Sources)
+				sourceIdx[src] = idx
+				sm.Sources = append(sm.Sources, src)
+			}
+			entry = [4]int{i + 1, idx, int(span.LineStart) + 1, int(span.ColumnStart) + 1}
+		}
+		if n := len(sm.Lines); n > 0 {
+			last := sm.Lines[n-1]
+			if last[1] == entry[1] && last[2] == entry[2] && last[3] == entry[3] {
+				continue // same position, the previous entry already covers it
+			}
+		}
+		sm.Lines = append(sm.Lines, entry)
+	}
+
+	return sb.String(), sm
+}
+
+// sourceMapPath makes project files relative to the workspace, so maps stay
+// valid when the project is moved around.
+func (cg *Codegen) sourceMapPath(path string) string {
+	workspace := cg.ProjectAnalysis.Options.Workspace
+	if workspace != "" && filepath.IsAbs(path) {
+		if rel, err := filepath.Rel(workspace, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(path)
+}
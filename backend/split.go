@@ -0,0 +1,221 @@
+package codegen
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/gluax-lang/gluax/common"
+	"github.com/gluax-lang/gluax/frontend/sema"
+	"github.com/gluax-lang/gluax/std"
+)
+
+// split builds write a lua file per gluax file. Every item already lives in
+// the public table, so modules only need the locals of the headers, the
+// realm file defines them and hands them to each module. Files can import each
+// other, so a module is not run in one go, the realm file runs the phases of
+// handleFiles one after the other, each of them for all modules:
+//
+//	-- sv_name.lua
+//	<headers>
+//	do
+//		local modules = {
+//			include("sv_name.src.main.lua"),
+//		};
+//		for phase = 1, 5 do
+//			for i = 1, #modules do
+//				modules[i](<header locals>, phase);
+//			end
+//		end
+//	end
+//	<entry>
+//
+//	-- sv_name.src.main.lua
+//	return function(<header locals>, __gluax_phase)
+//	if __gluax_phase == 1 then
+//	<classes of src/main.gluax>
+//	end
+//	...
+//	end
+//
+// Modules are included relative to the realm file, they are written next to it.
+
+// Module is a lua file of a split build.
+type Module struct {
+	File   string // file name, it lives next to the realm file
+	Source string // the gluax file it is generated from
+	Output
+}
+
+// LuaName is the name generated files are named after.
+func LuaName(pA *sema.ProjectAnalysis) string {
+	return strings.ToLower(pA.Config.Name)
+}
+
+func realmPrefix(state *sema.State) string {
+	if state.Label == "CLIENT" {
+		return "cl_"
+	}
+	return "sv_"
+}
+
+// modulePhase is the parameter of modules telling which phase to run.
+const modulePhase = "__gluax_phase"
+
+// generateModules generates the modules of a realm and the code of the realm
+// file that includes them, modules come after the ones they import.
+func (cg *Codegen) generateModules(state *sema.State) []Module {
+	params := strings.Join(slices.Sorted(maps.Keys(cg.chunkLocals)), ", ")
+	base := realmPrefix(state) + LuaName(cg.ProjectAnalysis)
+
+	var modules []Module
+	names := make(map[string]int)
+	for _, path := range moduleOrder(state.Files) {
+		analysis := state.Files[path]
+		if analysis.Ast == nil {
+			continue
+		}
+		body, ok := cg.generateModule(analysis)
+		if !ok {
+			continue // nothing to run, like files with only declarations
+		}
+
+		source := cg.sourceMapPath(path)
+		name := strings.ToLower(strings.ReplaceAll(strings.TrimSuffix(source, ".gluax"), "/", "."))
+		if n := names[name]; n > 0 {
+			names[name]++
+			name = fmt.Sprintf("%s_%d", name, n+1)
+		} else {
+			names[name] = 1
+		}
+		file := base + "." + name + ".lua"
+
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "--[[generated from %s]]\n", source)
+		fmt.Fprintf(&sb, "return function(%s, %s)\n", params, modulePhase)
+		sb.WriteString(body)
+		sb.WriteString("\nend\n")
+		modules = append(modules, Module{
+			File:   file,
+			Source: source,
+			Output: cg.finishChunk(sb.String(), ""),
+		})
+	}
+
+	cg.ln("--[[modules]]")
+	if len(modules) > 0 {
+		cg.ln("do")
+		cg.pushIndent()
+		cg.ln("local modules = {")
+		cg.pushIndent()
+		for _, module := range modules {
+			cg.ln("include(%q),", module.File)
+		}
+		cg.popIndent()
+		cg.ln("};")
+		cg.ln("for phase = 1, %d do", len(modulePhases))
+		cg.pushIndent()
+		cg.ln("for i = 1, #modules do")
+		cg.pushIndent()
+		cg.ln("modules[i](%s, phase);", params)
+		cg.popIndent()
+		cg.ln("end")
+		cg.popIndent()
+		cg.ln("end")
+		cg.popIndent()
+		cg.ln("end")
+	}
+	cg.ln("")
+	return modules
+}
+
+// modulePhases are the phases of handleFiles, a module runs one per call.
+var modulePhases = []func(cg *Codegen){
+	(*Codegen).generateClasses,
+	(*Codegen).generateTraitImpls,
+	func(cg *Codegen) {
+		analysis := cg.Analysis
+		cg.generateFunctions()
+		cg.generateLateFuncs()
+		cg.setAnalysis(analysis) // late functions switch to the file they are from
+	},
+	(*Codegen).generateLets,
+	(*Codegen).generateRoots,
+}
+
+// generateModule generates the code of one file, each phase behind a check of
+// the phase parameter. It reports false when there is no code.
+func (cg *Codegen) generateModule(analysis *Analysis) (string, bool) {
+	cg.pushTempScope()
+	oldBuf := cg.newBuf()
+	cg.setAnalysis(analysis)
+	for i, phase := range modulePhases {
+		phaseBuf := cg.newBuf()
+		phase(cg)
+		code := cg.restoreBuf(phaseBuf)
+		if isBlankCode(code) {
+			continue
+		}
+		cg.ln("if %s == %d then", modulePhase, i+1)
+		cg.writeString(strings.TrimRight(code, "\n") + "\n")
+		cg.ln("end")
+	}
+	generated := cg.restoreBuf(oldBuf)
+
+	oldBuf = cg.newBuf()
+	// the header locals and the phase are parameters of the module
+	generated = cg.emitLimitedTempLocals(generated, len(cg.chunkLocals)+1, "top level code of "+cg.sourceMapPath(analysis.Src), common.Span{})
+	cg.writeString(generated)
+	body := cg.restoreBuf(oldBuf)
+	return body, !isBlankCode(generated)
+}
+
+// isBlankCode reports whether generated code has nothing but whitespace and
+// span markers.
+func isBlankCode(code string) bool {
+	return strings.TrimSpace(strings.Map(func(r rune) rune {
+		if r == spanMarker || (r >= '0' && r <= '9') {
+			return -1
+		}
+		return r
+	}, code)) == ""
+}
+
+// moduleOrder orders the files of a realm so the ones a file imports come
+// before it. std goes first, everything can use it without importing it.
+func moduleOrder(files map[string]*sema.Analysis) []string {
+	isStd := func(path string) int {
+		if files[path].Workspace == std.Workspace {
+			return 0
+		}
+		return 1
+	}
+	paths := slices.Sorted(maps.Keys(files))
+	slices.SortStableFunc(paths, func(a, b string) int {
+		return cmp.Compare(isStd(a), isStd(b))
+	})
+
+	order := make([]string, 0, len(paths))
+	visited := make(map[string]struct{}, len(paths))
+	var visit func(path string)
+	visit = func(path string) {
+		if _, ok := visited[path]; ok {
+			return
+		}
+		visited[path] = struct{}{}
+		analysis, ok := files[path]
+		if !ok {
+			return
+		}
+		for _, dep := range analysis.Deps {
+			visit(dep)
+		}
+		order = append(order, path)
+	}
+	for _, path := range paths {
+		visit(path)
+	}
+	return order
+}
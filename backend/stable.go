@@ -0,0 +1,56 @@
+package codegen
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/gluax-lang/gluax/common"
+	"github.com/gluax-lang/gluax/frontend/ast"
+	"github.com/gluax-lang/gluax/frontend/sema"
+)
+
+// generated names are keyed by where things are defined, span IDs and pointers
+// depend on the order things got analyzed in and would make two builds of the
+// same source differ.
+
+// stableID identifies the item defined at span, it doesn't change when the
+// project is moved around.
+func (cg *Codegen) stableID(span common.Span) string {
+	source := common.SHA256Hex(cg.sourceMapPath(span.Source))[:8]
+	return fmt.Sprintf("_%s_%d_%d", source, span.LineStart, span.ColumnStart)
+}
+
+// classInstance is the index of cls among the instances of its generic class.
+func classInstance(cls *ast.SemClass) int {
+	for i, inst := range cls.Def.GetClassStack() {
+		if inst.Type == cls {
+			return i
+		}
+	}
+	return -1
+}
+
+func compareSpans(a, b common.Span) int {
+	return cmp.Or(
+		cmp.Compare(a.Source, b.Source),
+		cmp.Compare(a.LineStart, b.LineStart),
+		cmp.Compare(a.ColumnStart, b.ColumnStart),
+	)
+}
+
+// sortedMethodNames returns the names of methods in definition order.
+func sortedMethodNames(methods map[string]*sema.SemFunction) []string {
+	return slices.SortedFunc(maps.Keys(methods), func(a, b string) int {
+		return cmp.Or(compareSpans(methods[a].Def.Span(), methods[b].Def.Span()), cmp.Compare(a, b))
+	})
+}
+
+// sortedClasses returns the classes in definition order, instances of the same
+// class in the order they were created.
+func sortedClasses[V any](classes map[*ast.SemClass]V) []*ast.SemClass {
+	return slices.SortedFunc(maps.Keys(classes), func(a, b *ast.SemClass) int {
+		return cmp.Or(compareSpans(a.Def.Span(), b.Def.Span()), cmp.Compare(classInstance(a), classInstance(b)))
+	})
+}
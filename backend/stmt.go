@@ -10,6 +10,7 @@ import (
 func (cg *Codegen) genStmt(stmt ast.Stmt) (string, bool) {
 	releaseTemps := cg.collectTemps()
 	defer releaseTemps()
+	defer cg.setSpan(stmt.Span())()
 	switch stmt := stmt.(type) {
 	case *ast.StmtExpr:
 		if stmt.HasSemicolon {
@@ -39,28 +40,32 @@ func (cg *Codegen) genStmt(stmt ast.Stmt) (string, bool) {
 		cg.genStmtReturn(stmt)
 	case *ast.StmtThrow:
 		cg.genStmtThrow(stmt)
+	case *ast.StmtDefer:
+		cg.genStmtDefer(stmt)
 	}
 	return "nil", false
 }
 
 func (cg *Codegen) genStmtContinue(stmt *ast.StmtContinue) {
-	var label string
+	var loop loopLabel
 	if stmt.Label != nil {
-		label = frontend.CONTINUE_PREFIX + stmt.Label.Raw
+		loop = cg.loopByLabel(frontend.CONTINUE_PREFIX + stmt.Label.Raw)
 	} else {
-		label = cg.innermostLoop().cont
+		loop = cg.innermostLoop()
 	}
-	cg.ln("goto %s", label)
+	cg.genDefersFrom(loop.deferDepth)
+	cg.ln("goto %s", loop.cont)
 }
 
 func (cg *Codegen) genStmtBreak(stmt *ast.StmtBreak) {
-	var label string
+	var loop loopLabel
 	if stmt.Label != nil {
-		label = frontend.BREAK_PREFIX + stmt.Label.Raw
+		loop = cg.loopByLabel(frontend.BREAK_PREFIX + stmt.Label.Raw)
 	} else {
-		label = cg.innermostLoop().brk
+		loop = cg.innermostLoop()
 	}
-	cg.ln("goto %s", label)
+	cg.genDefersFrom(loop.deferDepth)
+	cg.ln("goto %s", loop.brk)
 }
 
 func (cg *Codegen) genStmtAssignment(stmt *ast.StmtAssignment) {
@@ -79,18 +84,25 @@ func (cg *Codegen) genStmtReturn(stmt *ast.StmtReturn) {
 			toReturn = "nil"
 		}
 		cg.ln("%s = %s;", strings.Join(funcScope.returnVars, ", "), toReturn)
+		cg.genDefersFrom(funcScope.deferDepth)
 		funcScope.usedLabel = true
 		cg.ln("goto %s;", funcScope.returnLabel)
 		return
 	}
 
 	if stmt.Exprs == nil {
+		cg.genDefersFrom(funcScope.deferDepth)
 		cg.ln("do return nil; end;")
 		return
 	}
 
 	retVals := cg.genExprsToStrings(stmt.Exprs)
 
+	if cg.hasDefersFrom(funcScope.deferDepth) {
+		retVals = cg.storeInTemps(strings.Join(retVals, ", "), funcScope.returnCount)
+		cg.genDefersFrom(funcScope.deferDepth)
+	}
+
 	if stmt.IsFuncErroable {
 		retVals = append([]string{"nil"}, retVals...)
 	}
@@ -99,14 +111,22 @@ func (cg *Codegen) genStmtReturn(stmt *ast.StmtReturn) {
 }
 
 func (cg *Codegen) genStmtThrow(stmt *ast.StmtThrow) {
+	cg.genPropagateError(cg.genExpr(stmt.Value))
+}
+
+// genPropagateError leaves the current function with an error value.
+func (cg *Codegen) genPropagateError(value string) {
 	funcScope := cg.currentFuncScope()
 	if funcScope.inlining {
-		value := cg.genExpr(stmt.Value)
 		cg.ln("%s = %s;", funcScope.errorVar, value)
+		cg.genDefersFrom(funcScope.deferDepth)
 		cg.ln("goto %s;", funcScope.returnLabel)
 		funcScope.usedLabel = true
 		return
 	}
-	value := cg.genExpr(stmt.Value)
+	if cg.hasDefersFrom(funcScope.deferDepth) {
+		value = cg.storeInTemps(value, 1)[0]
+		cg.genDefersFrom(funcScope.deferDepth)
+	}
 	cg.ln("do return %s; end;", value)
 }
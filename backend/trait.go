@@ -2,10 +2,12 @@ package codegen
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/gluax-lang/gluax/frontend"
 	"github.com/gluax-lang/gluax/frontend/ast"
+	"github.com/gluax-lang/gluax/frontend/sema"
 )
 
 func (cg *Codegen) decorateTraitName_internal(tr *ast.Trait, class *ast.SemClass) string {
@@ -13,28 +15,32 @@ func (cg *Codegen) decorateTraitName_internal(tr *ast.Trait, class *ast.SemClass
 	var sb strings.Builder
 	sb.WriteString(frontend.TRAIT_PREFIX)
 	sb.WriteString(raw)
-	sb.WriteString(fmt.Sprintf("_%d", tr.Span().ID))
+	sb.WriteString(cg.stableID(tr.Span()))
 	if class != nil {
 		sb.WriteString(cg.decorateClassName_internal(class))
 	}
 	return sb.String()
 }
 
-func (cg *Codegen) decorateTraitName(tr *ast.Trait, class *ast.SemClass) string {
-	raw := tr.Name.Raw
+func (cg *Codegen) decorateTraitName(trait *ast.SemTrait, class *ast.SemClass) string {
+	tr := trait.Def
 	var sb strings.Builder
 	sb.WriteString(frontend.TRAIT_PREFIX)
-	sb.WriteString(raw)
-	sb.WriteString(fmt.Sprintf("_%d", tr.Span().ID))
+	sb.WriteString(tr.Name.Raw)
+	sb.WriteString(cg.stableID(tr.Span()))
+	if trait.Generic != nil {
+		// every instance of a generic trait gets its own table
+		sb.WriteString(fmt.Sprintf("_%d", slices.Index(trait.Generic.Instances, trait)))
+	}
 	if class != nil {
 		sb.WriteString(cg.decorateClassName_internal(class))
 	}
 	baseName := sb.String()
 	var comment string
 	if class != nil {
-		comment = fmt.Sprintf("impl %s for %s", raw, class.Def.Name.Raw)
+		comment = fmt.Sprintf("impl %s for %s", trait.String(), class.Def.Name.Raw)
 	} else {
-		comment = fmt.Sprintf("trait %s", raw)
+		comment = fmt.Sprintf("trait %s", trait.String())
 	}
 	return cg.getPublic(baseName) + fmt.Sprintf(" --[[%s]]", comment)
 }
@@ -42,16 +48,20 @@ func (cg *Codegen) decorateTraitName(tr *ast.Trait, class *ast.SemClass) string
 func (cg *Codegen) genTraitImpl(tr *ast.SemTrait) {
 	classesAndMethods := cg.Analysis.GetClassesImplementingTrait(tr)
 
-	for class, methods := range classesAndMethods {
-		if !class.IsFullyConcrete() {
+	for _, class := range sortedClasses(classesAndMethods) {
+		methods := classesAndMethods[class]
+		if !class.IsFullyConcrete() || !cg.isReachable(class) {
 			continue
 		}
 
-		dTName := cg.decorateTraitName(tr.Def, class)
+		dTName := cg.decorateTraitName(tr, class)
 
 		cg.ln("%s = {", dTName)
 		cg.pushIndent()
 
+		slices.SortFunc(methods, func(a, b *sema.SemFunction) int {
+			return compareSpans(a.Def.Span(), b.Def.Span())
+		})
 		for _, m := range methods {
 			hMethod := cg.Analysis.HandleClassMethod(class, m, true)
 			cg.ln("%s = %s,", hMethod.Def.Name.Raw, cg.genFunction(hMethod))
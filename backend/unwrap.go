@@ -0,0 +1,65 @@
+package codegen
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gluax-lang/gluax/common"
+	"github.com/gluax-lang/gluax/frontend/ast"
+)
+
+// longest expression text quoted in a runtime check message
+const checkTextMaxLen = 60
+
+// genUnwrapNilable generates `x?`. Debug builds check the value so a nil fails
+// right where it got unwrapped, release builds and `#[unchecked]` functions
+// pass it through.
+func (cg *Codegen) genUnwrapNilable(p *ast.ExprPostfix, value string) string {
+	if cg.ProjectAnalysis.Options.Release {
+		return value
+	}
+	if scope := cg.currentFuncScope(); scope != nil && scope.unchecked {
+		return value
+	}
+	span := p.Left.Span()
+	msg := fmt.Sprintf("%s: unwrapped nil value", cg.checkPos(span))
+	if text := cg.sourceText(span); text != "" {
+		msg += fmt.Sprintf(" `%s`", text)
+	}
+	temp := cg.getTempVar()
+	cg.ln("%s = %s;", temp, value)
+	// level 0, the position of the generated code means nothing to the user
+	cg.ln("if %s == nil then error(%s, 0); end", temp, ast.QuoteLua(msg))
+	return temp
+}
+
+func (cg *Codegen) checkPos(span common.Span) string {
+	return fmt.Sprintf("%s:%d:%d", cg.sourceMapPath(span.Source), span.LineStart+1, span.ColumnStart+1)
+}
+
+// sourceText returns the source of span, shortened to its first line.
+func (cg *Codegen) sourceText(span common.Span) string {
+	lines, ok := cg.sourceLines[span.Source]
+	if !ok {
+		if file := cg.files[span.Source]; file != nil {
+			lines = strings.Split(file.Code, "\n")
+		}
+		cg.sourceLines[span.Source] = lines
+	}
+	if int(span.LineStart) >= len(lines) {
+		return ""
+	}
+	line := lines[span.LineStart]
+	start, end := int(span.ColumnStart), len(line)
+	if span.LineEnd == span.LineStart {
+		end = min(int(span.ColumnEnd), end)
+	}
+	if start >= end {
+		return ""
+	}
+	text := strings.TrimSpace(line[start:end])
+	if len(text) > checkTextMaxLen || span.LineEnd != span.LineStart {
+		text = text[:min(len(text), checkTextMaxLen)] + "..."
+	}
+	return text
+}
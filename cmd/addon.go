@@ -0,0 +1,334 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	codegen "github.com/gluax-lang/gluax/backend"
+	"github.com/gluax-lang/gluax/frontend"
+)
+
+const (
+	TargetFlat  = "flat"
+	TargetAddon = "addon"
+)
+
+// directories of the project copied as they are into addon builds
+var addonContentDirs = []string{"materials", "sound", "models"}
+
+const defaultAddonType = "tool"
+
+// outputLayout is where a build puts its files. Flat builds write everything
+// to out/, addon builds write a garry's mod addon to out/<name>/, the source
+// maps stay in out/ so `gluax trace` finds them either way. Addons of type
+// gamemode are a gamemode in gamemodes/<name>/ instead of code in lua/.
+type outputLayout struct {
+	target    string
+	workspace string
+	outDir    string
+	name      string
+	config    frontend.GluaxToml
+}
+
+func newOutputLayout(target, workspace string, config frontend.GluaxToml) (*outputLayout, error) {
+	l := &outputLayout{
+		target:    target,
+		workspace: workspace,
+		outDir:    filepath.Join(workspace, "out"),
+		name:      strings.ToLower(config.Name),
+		config:    config,
+	}
+	if err := os.MkdirAll(l.outDir, 0755); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *outputLayout) serverFile() string { return "sv_" + l.name + ".lua" }
+func (l *outputLayout) clientFile() string { return "cl_" + l.name + ".lua" }
+
+func (l *outputLayout) addonDir() string {
+	return filepath.Join(l.outDir, l.name)
+}
+
+func (l *outputLayout) isGamemode() bool {
+	return l.target == TargetAddon && l.config.Addon.Type == "gamemode"
+}
+
+func (l *outputLayout) gamemodeDir() string {
+	return filepath.Join(l.addonDir(), "gamemodes", l.name)
+}
+
+// luaDir is the directory of the generated lua, its path in game is
+// `<name>/<file>`, gamemodes load it from their gamemode/ directory.
+func (l *outputLayout) luaDir() string {
+	switch {
+	case l.isGamemode():
+		return filepath.Join(l.gamemodeDir(), "gamemode")
+	case l.target == TargetAddon:
+		return filepath.Join(l.addonDir(), "lua", l.name)
+	}
+	return l.outDir
+}
+
+// writeRealm writes the code of a realm and its modules, removing the modules
+// of earlier builds it doesn't load anymore. It tells if anything changed.
+func (l *outputLayout) writeRealm(fileName string, out codegen.Output) (bool, error) {
+	if err := os.MkdirAll(l.luaDir(), 0755); err != nil {
+		return false, err
+	}
+	changed, err := writeOutputTo(l.luaDir(), l.outDir, fileName, out)
+	if err != nil {
+		return changed, err
+	}
+	kept := make(map[string]struct{}, len(out.Modules))
+	for _, module := range out.Modules {
+		kept[module.File] = struct{}{}
+		written, err := writeOutputTo(l.luaDir(), l.outDir, module.File, module.Output)
+		changed = changed || written
+		if err != nil {
+			return changed, err
+		}
+	}
+
+	stale, err := l.modules(fileName)
+	if err != nil {
+		return changed, err
+	}
+	for _, module := range stale {
+		if _, ok := kept[module]; ok {
+			continue
+		}
+		if err := os.Remove(filepath.Join(l.luaDir(), module)); err != nil {
+			return changed, err
+		}
+		if err := os.Remove(filepath.Join(l.outDir, module+".map")); err != nil && !os.IsNotExist(err) {
+			return changed, err
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+// modules lists the module files of a realm file in luaDir, sorted, for
+// sv_name.lua those are sv_name.<module>.lua.
+func (l *outputLayout) modules(fileName string) ([]string, error) {
+	pattern := strings.TrimSuffix(fileName, ".lua") + ".*.lua"
+	matches, err := filepath.Glob(filepath.Join(l.luaDir(), pattern))
+	if err != nil {
+		return nil, err
+	}
+	for i, match := range matches {
+		matches[i] = filepath.Base(match)
+	}
+	return matches, nil
+}
+
+// finish writes what goes around the generated code, for addons the autorun
+// bootstrap or the files of the gamemode, addon.json and the content
+// directories.
+func (l *outputLayout) finish() (bool, error) {
+	if l.target != TargetAddon {
+		return false, nil
+	}
+	changed := false
+	write := func(path string, data []byte) error {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		written, err := writeIfChanged(path, data)
+		changed = changed || written
+		return err
+	}
+
+	var files map[string]string
+	var err error
+	if l.isGamemode() {
+		files, err = l.gamemodeFiles()
+	} else {
+		var bootstrap string
+		bootstrap, err = l.bootstrap()
+		files = map[string]string{
+			filepath.Join(l.addonDir(), "lua", "autorun", l.name+"_init.lua"): bootstrap,
+		}
+	}
+	if err != nil {
+		return changed, err
+	}
+	for path, content := range files {
+		if err := write(path, []byte(content)); err != nil {
+			return changed, err
+		}
+	}
+	addonJSON, err := l.addonJSON()
+	if err != nil {
+		return changed, err
+	}
+	if err := write(filepath.Join(l.addonDir(), "addon.json"), addonJSON); err != nil {
+		return changed, err
+	}
+	for _, dir := range addonContentDirs {
+		copied, err := syncDir(filepath.Join(l.workspace, dir), filepath.Join(l.addonDir(), dir))
+		changed = changed || copied
+		if err != nil {
+			return changed, err
+		}
+	}
+	return changed, nil
+}
+
+// bootstrap is lua/autorun/<name>_init.lua, it runs in both realms, sends the
+// client code to clients and includes the code of the realm it runs in.
+func (l *outputLayout) bootstrap() (string, error) {
+	var sb strings.Builder
+	sb.WriteString("-- generated by gluax, do not edit\n")
+	sb.WriteString("if SERVER then\n")
+	sb.WriteString("\tAddCSLuaFile()\n")
+	if err := l.sendClientFiles(&sb, "\t", l.name+"/"); err != nil {
+		return "", err
+	}
+	fmt.Fprintf(&sb, "\tinclude(%q)\n", l.name+"/"+l.serverFile())
+	sb.WriteString("else\n")
+	fmt.Fprintf(&sb, "\tinclude(%q)\n", l.name+"/"+l.clientFile())
+	sb.WriteString("end\n")
+	return sb.String(), nil
+}
+
+// gamemodeFiles are what garry's mod loads a gamemode from, by path: its
+// <name>.txt, and gamemode/init.lua and gamemode/cl_init.lua which include the
+// code of their realm, it is next to them.
+func (l *outputLayout) gamemodeFiles() (map[string]string, error) {
+	var server strings.Builder
+	server.WriteString("-- generated by gluax, do not edit\n")
+	server.WriteString("AddCSLuaFile(\"cl_init.lua\")\n")
+	if err := l.sendClientFiles(&server, "", ""); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(&server, "include(%q)\n", l.serverFile())
+
+	client := fmt.Sprintf("-- generated by gluax, do not edit\ninclude(%q)\n", l.clientFile())
+
+	title := l.config.Addon.Title
+	if title == "" {
+		title = l.config.Name
+	}
+	// key values have no escapes
+	title = strings.ReplaceAll(title, `"`, "'")
+	info := fmt.Sprintf("\"%s\"\n{\n\t\"base\"\t\t\"base\"\n\t\"title\"\t\t\"%s\"\n\t\"maps\"\t\t\"\"\n\t\"menusystem\"\t\"1\"\n}\n", l.name, title)
+
+	return map[string]string{
+		filepath.Join(l.luaDir(), "init.lua"):         server.String(),
+		filepath.Join(l.luaDir(), "cl_init.lua"):      client,
+		filepath.Join(l.gamemodeDir(), l.name+".txt"): info,
+	}, nil
+}
+
+// sendClientFiles writes the AddCSLuaFile calls of the client code, split
+// builds also send the client modules, the client file loads them.
+func (l *outputLayout) sendClientFiles(sb *strings.Builder, indent, dir string) error {
+	modules, err := l.modules(l.clientFile())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(sb, "%sAddCSLuaFile(%q)\n", indent, dir+l.clientFile())
+	for _, module := range modules {
+		fmt.Fprintf(sb, "%sAddCSLuaFile(%q)\n", indent, dir+module)
+	}
+	return nil
+}
+
+func (l *outputLayout) addonJSON() ([]byte, error) {
+	addon := l.config.Addon
+	title := addon.Title
+	if title == "" {
+		title = l.config.Name
+	}
+	addonType := addon.Type
+	if addonType == "" {
+		addonType = defaultAddonType
+	}
+	tags := addon.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	ignore := addon.Ignore
+	if ignore == nil {
+		ignore = []string{}
+	}
+	data, err := json.MarshalIndent(struct {
+		Title       string   `json:"title"`
+		Description string   `json:"description,omitempty"`
+		Type        string   `json:"type"`
+		Tags        []string `json:"tags"`
+		Ignore      []string `json:"ignore"`
+	}{title, addon.Description, addonType, tags, ignore}, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// syncDir makes dst a copy of src, only writing the files that changed and
+// removing the ones src doesn't have anymore.
+func syncDir(src, dst string) (bool, error) {
+	changed := false
+	kept := make(map[string]struct{})
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == src {
+				return fs.SkipDir
+			}
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		kept[target] = struct{}{}
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		written, err := writeIfChanged(target, data)
+		changed = changed || written
+		return err
+	})
+	if err != nil {
+		return changed, err
+	}
+
+	var stale []string
+	err = filepath.WalkDir(dst, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dst {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if _, ok := kept[path]; !ok {
+			stale = append(stale, path)
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return changed, err
+	}
+	for _, path := range stale {
+		if err := os.RemoveAll(path); err != nil {
+			return changed, err
+		}
+		changed = true
+	}
+	return changed, nil
+}
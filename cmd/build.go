@@ -1,6 +1,9 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -10,8 +13,21 @@ import (
 )
 
 type BuildCmd struct {
-	Path    string `help:"Path to the project directory." short:"p" default:"."`
-	Release bool   `help:"Build in release mode." short:"r"`
+	Path string `help:"Path to the project directory." short:"p" default:"."`
+	Emit string `help:"Output style: pretty, compact or minified." enum:"pretty,compact,minified" default:"pretty"`
+	// Target is how the output is laid out, see outputLayout
+	Target string `help:"Output layout: flat files in out/, or a garry's mod addon in out/<name>/." enum:"flat,addon" default:"flat"`
+	ProfileFlags
+
+	RewriteErrors bool `help:"Embed the source map and rewrite runtime errors to gluax positions."`
+	Split         bool `help:"Write a lua file per gluax file, loaded by the realm file next to them."`
+	PrintUnused   bool `help:"Print project items that are not reachable from the roots."`
+	InlineReport  bool `help:"Print why each project function is or isn't inlined."`
+	// Roots override `roots` of gluax.toml
+	Roots []string `help:"What release builds keep: main, exports and/or hooks. Defaults to roots of gluax.toml, or all of them." placeholder:"ROOT"`
+
+	VerifyReproducible bool `help:"Build twice and fail if the outputs differ."`
+	Watch              bool `help:"Keep running and rebuild when src/ or gluax.toml change." short:"w"`
 }
 
 func (b *BuildCmd) Run() error {
@@ -20,9 +36,30 @@ func (b *BuildCmd) Run() error {
 		return err
 	}
 
+	emit, err := sema.ParseEmitMode(b.Emit)
+	if err != nil {
+		return err
+	}
+
 	options := sema.CompileOptions{
 		Workspace: absPath,
-		Release:   b.Release,
+		Emit:      emit,
+
+		RewriteErrors: b.RewriteErrors,
+		Split:         b.Split,
+	}
+	if b.Split && b.RewriteErrors {
+		return fmt.Errorf("--rewrite-errors can't be used with --split")
+	}
+	if options.Roots, err = sema.ParseRoots(b.Roots); err != nil {
+		return err
+	}
+	if err := b.apply(&options); err != nil {
+		return err
+	}
+
+	if b.Watch {
+		return b.watch(options)
 	}
 
 	pAnalysis, err := sema.AnalyzeProject(options)
@@ -30,23 +67,166 @@ func (b *BuildCmd) Run() error {
 		return err
 	}
 
-	name := strings.ToLower(pAnalysis.Config.Name)
-
-	outDir := filepath.Join(absPath, "out")
-	if err := os.MkdirAll(outDir, 0755); err != nil {
+	layout, err := newOutputLayout(b.Target, absPath, pAnalysis.Config)
+	if err != nil {
 		return err
 	}
 
-	serverCode, clientCode := codegen.GenerateProject(pAnalysis)
+	server, client := codegen.GenerateProject(pAnalysis)
+	if errs := append(server.Errors, client.Errors...); len(errs) > 0 {
+		for _, e := range errs {
+			fmt.Fprintf(os.Stderr, "error: %s: %s\n", spanPos(absPath, e.Span), e.Message)
+		}
+		return fmt.Errorf("code generation failed with %d errors", len(errs))
+	}
 
-	svPath := filepath.Join(outDir, "sv_"+name+".lua")
-	clPath := filepath.Join(outDir, "cl_"+name+".lua")
+	if b.VerifyReproducible {
+		if err := verifyReproducible(options, layout, server, client); err != nil {
+			return err
+		}
+	}
 
-	if err := os.WriteFile(svPath, []byte(serverCode), 0644); err != nil {
+	if _, err := layout.writeRealm(layout.serverFile(), server); err != nil {
 		return err
 	}
-	if err := os.WriteFile(clPath, []byte(clientCode), 0644); err != nil {
+	if _, err := layout.writeRealm(layout.clientFile(), client); err != nil {
 		return err
 	}
+	if _, err := layout.finish(); err != nil {
+		return err
+	}
+
+	if b.PrintUnused {
+		printUnused(pAnalysis, absPath)
+	}
+	if b.InlineReport {
+		printInlineReport(pAnalysis, absPath)
+	}
 	return nil
 }
+
+func printUnused(pAnalysis *sema.ProjectAnalysis, workspace string) {
+	state := pAnalysis.ServerState()
+	reach := pAnalysis.Reachable(state, pAnalysis.Roots())
+	for _, item := range pAnalysis.Unused(state, reach) {
+		fmt.Printf("unused %s `%s` (%s)\n", item.Kind, item.Name, spanPos(workspace, item.Span))
+	}
+}
+
+func printInlineReport(pAnalysis *sema.ProjectAnalysis, workspace string) {
+	if !pAnalysis.Options.Release {
+		fmt.Println("note: nothing is inlined outside of release builds")
+	}
+	entries, skips := pAnalysis.InlinePolicy(pAnalysis.ServerState()).Report()
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Span.Source, workspace) {
+			continue
+		}
+		verdict := "keep"
+		if entry.Inline {
+			verdict = "inline"
+		}
+		fmt.Printf("%s `%s` (%s): %s\n", verdict, entry.Name, spanPos(workspace, entry.Span), entry.Reason)
+	}
+	for _, skip := range skips {
+		fmt.Printf("call of `%s` not inlined (%s): %s\n", skip.Callee, spanPos(workspace, skip.Span), skip.Reason)
+	}
+}
+
+// spanPos formats the start of span as path:line:column, relative to workspace.
+func spanPos(workspace string, span sema.Span) string {
+	if span.Source == "" {
+		return "<generated>"
+	}
+	path := span.Source
+	if rel, err := filepath.Rel(workspace, path); err == nil && !strings.HasPrefix(rel, "..") {
+		path = rel
+	}
+	return fmt.Sprintf("%s:%d:%d", filepath.ToSlash(path), span.LineStart+1, span.ColumnStart+1)
+}
+
+// verifyReproducible builds the project again and compares the result with
+// the first build.
+func verifyReproducible(options sema.CompileOptions, layout *outputLayout, server, client codegen.Output) error {
+	pAnalysis, err := sema.AnalyzeProject(options)
+	if err != nil {
+		return err
+	}
+	server2, client2 := codegen.GenerateProject(pAnalysis)
+	outputs := []struct {
+		file          string
+		first, second codegen.Output
+	}{
+		{layout.serverFile(), server, server2},
+		{layout.clientFile(), client, client2},
+	}
+	for _, out := range outputs {
+		if len(out.first.Modules) != len(out.second.Modules) {
+			return fmt.Errorf("build is not reproducible, %s loads %d modules then %d", out.file, len(out.first.Modules), len(out.second.Modules))
+		}
+		for i, first := range out.first.Modules {
+			second := out.second.Modules[i]
+			if first.File != second.File {
+				return fmt.Errorf("build is not reproducible, %s loads %s then %s", out.file, first.File, second.File)
+			}
+			if err := compareOutputs(first.File, first.Code, second.Code); err != nil {
+				return err
+			}
+		}
+		if err := compareOutputs(out.file, out.first.Code, out.second.Code); err != nil {
+			return err
+		}
+		first, _ := json.Marshal(out.first.Map)
+		second, _ := json.Marshal(out.second.Map)
+		if err := compareOutputs(out.file+".map", string(first), string(second)); err != nil {
+			return err
+		}
+	}
+	fmt.Println("build is reproducible")
+	return nil
+}
+
+func compareOutputs(file, first, second string) error {
+	if first == second {
+		return nil
+	}
+	firstLines, secondLines := strings.Split(first, "\n"), strings.Split(second, "\n")
+	for i := range max(len(firstLines), len(secondLines)) {
+		var a, b string
+		if i < len(firstLines) {
+			a = firstLines[i]
+		}
+		if i < len(secondLines) {
+			b = secondLines[i]
+		}
+		if a != b {
+			return fmt.Errorf("build is not reproducible, %s differs at line %d:\n  first:  %s\n  second: %s", file, i+1, a, b)
+		}
+	}
+	return fmt.Errorf("build is not reproducible, %s differs", file)
+}
+
+// writeOutputTo writes the generated lua file to luaDir and its source map to
+// mapDir, it tells if any of them changed.
+func writeOutputTo(luaDir, mapDir, fileName string, out codegen.Output) (bool, error) {
+	written, err := writeIfChanged(filepath.Join(luaDir, fileName), []byte(out.Code))
+	if err != nil {
+		return false, err
+	}
+	out.Map.File = fileName
+	mapData, err := json.Marshal(out.Map)
+	if err != nil {
+		return false, err
+	}
+	mapWritten, err := writeIfChanged(filepath.Join(mapDir, fileName+".map"), mapData)
+	return written || mapWritten, err
+}
+
+// writeIfChanged leaves the file alone when it already has data, so garry's
+// mod autorefresh doesn't reload files that didn't change.
+func writeIfChanged(path string, data []byte) (bool, error) {
+	if old, err := os.ReadFile(path); err == nil && bytes.Equal(old, data) {
+		return false, nil
+	}
+	return true, os.WriteFile(path, data, 0644)
+}
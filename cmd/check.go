@@ -1,13 +1,17 @@
 package main
 
 import (
+	"maps"
 	"path/filepath"
+	"slices"
 
 	"github.com/gluax-lang/gluax/frontend/sema"
+	protocol "github.com/gluax-lang/lsp"
 )
 
 type CheckCmd struct {
 	Path string `help:"Path to the project directory." short:"p" default:"."`
+	ProfileFlags
 }
 
 func (c *CheckCmd) Run() error {
@@ -20,24 +24,31 @@ func (c *CheckCmd) Run() error {
 		options := sema.CompileOptions{
 			Workspace: absPath,
 		}
+		if err := c.apply(&options); err != nil {
+			return err
+		}
 
 		pAnalysis, err := sema.AnalyzeProject(options)
 		if err != nil {
 			return err
 		}
 
-		for _, file := range pAnalysis.ServerFiles() {
-			for _, diag := range file.Diags {
-				println("SERVER", diag.Message)
-			}
-		}
+		printDiagnostics("SERVER", pAnalysis.ServerFiles())
+		printDiagnostics("CLIENT", pAnalysis.ClientFiles())
+	}
 
-		for _, file := range pAnalysis.ClientFiles() {
-			for _, diag := range file.Diags {
-				println("CLIENT", diag.Message)
+	return nil
+}
+
+// printDiagnostics prints the diagnostics of a realm's files, ordered by path.
+func printDiagnostics(realm string, files map[string]*sema.Analysis) (errors int) {
+	for _, path := range slices.Sorted(maps.Keys(files)) {
+		for _, diag := range files[path].Diags {
+			println(realm, diag.Message)
+			if diag.Severity == nil || *diag.Severity == protocol.DiagnosticSeverityError {
+				errors++
 			}
 		}
 	}
-
-	return nil
+	return errors
 }
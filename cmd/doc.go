@@ -0,0 +1,507 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gluax-lang/gluax/common"
+	"github.com/gluax-lang/gluax/frontend/ast"
+	"github.com/gluax-lang/gluax/frontend/sema"
+	"github.com/gluax-lang/gluax/std"
+)
+
+type DocCmd struct {
+	Path   string `help:"Path to the project directory." short:"p" default:"."`
+	Format string `help:"Output format: markdown or html." enum:"markdown,html" default:"markdown"`
+	Output string `help:"Where to write the documentation, out/doc/<name>.md or .html by default." short:"o"`
+	Std    bool   `help:"Document the standard library instead of the project."`
+	ProfileFlags
+}
+
+// Run documents the public items of the project, or of std, in one page.
+func (d *DocCmd) Run() error {
+	absPath, err := filepath.Abs(d.Path)
+	if err != nil {
+		return err
+	}
+	options := sema.CompileOptions{Workspace: absPath}
+	if err := d.apply(&options); err != nil {
+		return err
+	}
+
+	workspace := absPath
+	if d.Std {
+		// std gets analyzed along any project, an empty library will do
+		tmp, err := os.MkdirTemp("", "gluax-doc")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmp)
+		options.Workspace = tmp
+		options.VirtualFiles = map[string]string{
+			filepath.Join(tmp, "gluax.toml"):       "name = \"doc\"\nversion = \"0.1\"\nlib = true\n",
+			filepath.Join(tmp, "src", "lib.gluax"): "",
+		}
+		workspace = std.Workspace
+	}
+
+	pAnalysis, err := sema.AnalyzeProject(options)
+	if err != nil {
+		return err
+	}
+	name := pAnalysis.Config.Name
+	if d.Std {
+		name = "std"
+	}
+	pkg := collectDocs(name, workspace, pAnalysis)
+
+	var (
+		data []byte
+		ext  string
+	)
+	switch d.Format {
+	case "html":
+		data, err = renderDocHTML(pkg)
+		ext = ".html"
+	default:
+		data, err = renderDocMarkdown(pkg), nil
+		ext = ".md"
+	}
+	if err != nil {
+		return err
+	}
+
+	output := d.Output
+	if output == "" {
+		output = filepath.Join(absPath, "out", "doc", strings.ToLower(name)+ext)
+	}
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(output, data, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %s\n", output)
+	return nil
+}
+
+// docPackage is what `gluax doc` renders, the public items of a package by
+// the file they are defined in.
+type docPackage struct {
+	Name    string
+	Modules []*docModule
+}
+
+type docModule struct {
+	Path    string // relative to the package
+	Classes []*docClass
+	Traits  []*docTrait
+	Funcs   []*docItem
+	Lets    []*docItem
+	Aliases []*docItem
+}
+
+// docItem is anything with a signature, Realm tells which realms have it
+type docItem struct {
+	Name      string
+	Signature string
+	Doc       string
+	Realm     sema.Realm
+	line      uint32 // to keep the order of the source
+}
+
+type docClass struct {
+	docItem
+	Fields     []*docItem
+	Methods    []*docItem
+	Implements []string
+}
+
+type docTrait struct {
+	docItem
+	Methods      []*docItem
+	Implementors []string
+}
+
+// RealmLabel is how the realms of an item are shown.
+func (item *docItem) RealmLabel() string {
+	switch item.Realm {
+	case sema.RealmServer:
+		return "server"
+	case sema.RealmClient:
+		return "client"
+	}
+	return "shared"
+}
+
+// collectDocs gathers the public items of the files of workspace. Each realm
+// is analyzed on its own, an item shows up in the realms that kept it after
+// `#[cfg(...)]` and `#if`.
+func collectDocs(name, workspace string, pA *sema.ProjectAnalysis) *docPackage {
+	c := &docCollector{
+		workspace: workspace,
+		modules:   make(map[string]*docModule),
+		items:     make(map[string]*docItem),
+		classes:   make(map[string]*docClass),
+		traits:    make(map[string]*docTrait),
+	}
+	c.collect(sema.RealmServer, pA.ServerState())
+	c.collect(sema.RealmClient, pA.ClientState())
+
+	pkg := &docPackage{Name: name}
+	for _, path := range slices.Sorted(maps.Keys(c.modules)) {
+		module := c.modules[path]
+		sortDocItems(module.Funcs)
+		sortDocItems(module.Lets)
+		sortDocItems(module.Aliases)
+		slices.SortStableFunc(module.Classes, func(a, b *docClass) int { return compareDocItems(&a.docItem, &b.docItem) })
+		slices.SortStableFunc(module.Traits, func(a, b *docTrait) int { return compareDocItems(&a.docItem, &b.docItem) })
+		for _, class := range module.Classes {
+			sortDocItems(class.Fields)
+			sortDocItems(class.Methods)
+			slices.Sort(class.Implements)
+			class.Implements = slices.Compact(class.Implements)
+		}
+		for _, trait := range module.Traits {
+			sortDocItems(trait.Methods)
+			slices.Sort(trait.Implementors)
+			trait.Implementors = slices.Compact(trait.Implementors)
+		}
+		pkg.Modules = append(pkg.Modules, module)
+	}
+	return pkg
+}
+
+type docCollector struct {
+	workspace string
+	modules   map[string]*docModule
+	// items by a key unique in the package, to merge the realms
+	items   map[string]*docItem
+	classes map[string]*docClass
+	traits  map[string]*docTrait
+}
+
+func (c *docCollector) collect(realm sema.Realm, state *sema.State) {
+	classKeys := make(map[*ast.Class]string)
+	traitKeys := make(map[*ast.SemTrait]string)
+
+	for _, path := range slices.Sorted(maps.Keys(state.Files)) {
+		analysis := state.Files[path]
+		rel, ok := c.relPath(path)
+		if !ok || analysis.Ast == nil {
+			continue
+		}
+		module := c.module(rel)
+		tree := analysis.Ast
+
+		for _, def := range tree.Classes {
+			if !def.Cfg.Active() || !def.Public {
+				continue
+			}
+			key := rel + "#class." + def.Name.Raw
+			classKeys[def] = key
+			class, isNew := c.class(key, realm, def)
+			if isNew {
+				module.Classes = append(module.Classes, class)
+			}
+			sem := analysis.Scope.GetType(def.Name.Raw)
+			for _, field := range def.Fields {
+				if !field.Cfg.Active() || !field.Public {
+					continue
+				}
+				signature := field.Name.Raw
+				if sem != nil && sem.IsClass() {
+					if semField, ok := sem.Class().Fields[field.Name.Raw]; ok {
+						signature += ": " + semField.Ty.String()
+					}
+				}
+				item, isNew := c.item(key+".field."+field.Name.Raw, realm, field.Name.Raw, signature, field.Doc, field.Name.Span())
+				if isNew {
+					class.Fields = append(class.Fields, item)
+				}
+			}
+		}
+
+		for _, def := range tree.Traits {
+			if !def.Cfg.Active() || !def.Public || def.Sem == nil {
+				continue
+			}
+			key := rel + "#trait." + def.Name.Raw
+			traitKeys[def.Sem] = key
+			trait, isNew := c.trait(key, realm, def)
+			if isNew {
+				module.Traits = append(module.Traits, trait)
+			}
+			for _, method := range def.Methods {
+				if !method.Cfg.Active() {
+					continue
+				}
+				semMethod := def.Sem.Methods[method.Name.Raw]
+				if semMethod == nil {
+					continue
+				}
+				item, isNew := c.item(key+".method."+method.Name.Raw, realm, method.Name.Raw, funcSignature(semMethod), method.Doc, method.Name.Span())
+				if isNew {
+					trait.Methods = append(trait.Methods, item)
+				}
+			}
+		}
+
+		for _, def := range tree.Funcs {
+			if !def.Cfg.Active() || !def.Public || def.Sem() == nil {
+				continue
+			}
+			item, isNew := c.item(rel+"#func."+def.Name.Raw, realm, def.Name.Raw, funcSignature(def.Sem()), def.Doc, def.Name.Span())
+			if isNew {
+				module.Funcs = append(module.Funcs, item)
+			}
+		}
+
+		for _, def := range tree.Lets {
+			if !def.Cfg.Active() || !def.Public {
+				continue
+			}
+			for _, name := range def.Names {
+				keyword := "let "
+				if def.IsConst {
+					keyword = "const "
+				}
+				signature := keyword + name.Raw
+				if value := analysis.Scope.GetValue(name.Raw); value != nil && value.IsVariable() {
+					signature += ": " + value.Type().String()
+				}
+				item, isNew := c.item(rel+"#let."+name.Raw, realm, name.Raw, signature, def.Doc, name.Span())
+				if isNew {
+					module.Lets = append(module.Lets, item)
+				}
+			}
+		}
+
+		for _, def := range tree.TypeAliases {
+			if !def.Cfg.Active() || !def.Public {
+				continue
+			}
+			signature := "type " + def.Name.Raw + def.Generics.String()
+			if sym := analysis.Scope.GetSymbol(def.Name.Raw); sym != nil && sym.IsTypeAlias() {
+				signature = sym.TypeAlias().LSPString()
+			}
+			item, isNew := c.item(rel+"#type."+def.Name.Raw, realm, def.Name.Raw, signature, def.Doc, def.Name.Span())
+			if isNew {
+				module.Aliases = append(module.Aliases, item)
+			}
+		}
+	}
+
+	// methods and trait implementations can be anywhere, even outside of the
+	// package for classes of std
+	for classDef, byName := range state.MethodsByClass {
+		key, ok := classKeys[classDef]
+		if !ok {
+			continue
+		}
+		class := c.classes[key]
+		for name, entries := range byName {
+			for _, entry := range entries {
+				method := entry.Method
+				if !method.Def.Public {
+					continue
+				}
+				// impls of different instances can have methods of the same name
+				methodKey, signature := key+".method."+name, funcSignature(method)
+				if impl := implType(classDef, entry.TypeParameters); impl != "" {
+					methodKey = key + ".impl." + impl + ".method." + name
+					signature = "impl " + impl + " {\n    " + signature + "\n}"
+				}
+				item, isNew := c.item(methodKey, realm, name, signature, method.Def.Doc, method.Def.Span())
+				if isNew {
+					class.Methods = append(class.Methods, item)
+				}
+			}
+		}
+	}
+	for classDef, byTrait := range state.TraitsByClass {
+		classKey, documented := classKeys[classDef]
+		for trait := range byTrait {
+			if documented {
+				class := c.classes[classKey]
+				class.Implements = append(class.Implements, trait.String())
+			}
+			if key, ok := traitKeys[trait.Origin()]; ok {
+				c.traits[key].Implementors = append(c.traits[key].Implementors, classDef.Name.Raw)
+			}
+		}
+	}
+}
+
+// relPath is path relative to the package, it tells if path is in it.
+func (c *docCollector) relPath(path string) (string, bool) {
+	prefix := common.FilePathClean(c.workspace) + "/"
+	if !strings.HasPrefix(path, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(path, prefix), true
+}
+
+func (c *docCollector) module(path string) *docModule {
+	module, ok := c.modules[path]
+	if !ok {
+		module = &docModule{Path: path}
+		c.modules[path] = module
+	}
+	return module
+}
+
+// item returns the item of key, with realm added to it, and tells if it is
+// new.
+func (c *docCollector) item(key string, realm sema.Realm, name, signature, doc string, span common.Span) (*docItem, bool) {
+	if item, ok := c.items[key]; ok {
+		item.Realm |= realm
+		return item, false
+	}
+	item := &docItem{Name: name, Signature: signature, Doc: doc, Realm: realm, line: span.LineStart}
+	c.items[key] = item
+	return item, true
+}
+
+func (c *docCollector) class(key string, realm sema.Realm, def *ast.Class) (*docClass, bool) {
+	if class, ok := c.classes[key]; ok {
+		class.Realm |= realm
+		return class, false
+	}
+	class := &docClass{docItem: docItem{
+		Name:      def.Name.Raw,
+		Signature: classSignature(def),
+		Doc:       def.Doc,
+		Realm:     realm,
+		line:      def.Name.Span().LineStart,
+	}}
+	c.classes[key] = class
+	return class, true
+}
+
+func (c *docCollector) trait(key string, realm sema.Realm, def *ast.Trait) (*docTrait, bool) {
+	if trait, ok := c.traits[key]; ok {
+		trait.Realm |= realm
+		return trait, false
+	}
+	signature := "trait " + def.Name.Raw
+	if len(def.SuperTraits) > 0 {
+		supers := make([]string, len(def.SuperTraits))
+		for i := range def.SuperTraits {
+			supers[i] = def.SuperTraits[i].String()
+		}
+		signature += ": " + strings.Join(supers, " + ")
+	}
+	trait := &docTrait{docItem: docItem{
+		Name:      def.Name.Raw,
+		Signature: signature,
+		Doc:       def.Doc,
+		Realm:     realm,
+		line:      def.Name.Span().LineStart,
+	}}
+	c.traits[key] = trait
+	return trait, true
+}
+
+func classSignature(def *ast.Class) string {
+	if def.Underlying != nil {
+		return "newtype " + def.Name.Raw + " = " + def.Underlying.String()
+	}
+	signature := "class " + def.Name.Raw + def.Generics.String()
+	if def.Super != nil {
+		if super, ok := (*def.Super).(*ast.Path); ok {
+			signature += ": " + super.String()
+		}
+	}
+	return signature
+}
+
+// funcSignature is a function as it is declared, with the names of its
+// parameters and their default values.
+func funcSignature(f *ast.SemFunction) string {
+	var sb strings.Builder
+	sb.WriteString("func ")
+	sb.WriteString(f.Def.Name.Raw)
+	sb.WriteString(f.Generics.String())
+	sb.WriteString("(")
+	for i, ty := range f.Params {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		var name string
+		if i < len(f.Def.Params) && f.Def.Params[i].Name != nil {
+			name = f.Def.Params[i].Name.Raw
+		}
+		switch {
+		case name == "self":
+			sb.WriteString("self")
+		case name == "" || ty.IsVararg():
+			sb.WriteString(ty.String())
+		default:
+			sb.WriteString(name)
+			sb.WriteString(": ")
+			sb.WriteString(ty.String())
+			if def := f.Def.Params[i].Default; def != nil {
+				sb.WriteString(" = ")
+				sb.WriteString(literalString(def))
+			}
+		}
+	}
+	sb.WriteString(")")
+	if f.Def.Errorable {
+		sb.WriteString(" !")
+		if f.Def.ErrorType != nil {
+			sb.WriteString(f.Error.String())
+		}
+	}
+	if !f.Return.IsNil() {
+		sb.WriteString(" -> ")
+		sb.WriteString(f.Return.String())
+	}
+	return sb.String()
+}
+
+// literalString is a literal as it is written, defaults of parameters have to
+// be literals.
+func literalString(e *ast.Expr) string {
+	switch e.Kind() {
+	case ast.ExprKindNil:
+		return "nil"
+	case ast.ExprKindBool:
+		return strconv.FormatBool(e.Bool())
+	case ast.ExprKindNumber:
+		return e.Number().Raw
+	case ast.ExprKindString:
+		return strconv.Quote(e.String().Raw)
+	case ast.ExprKindUnary:
+		return "-" + literalString(&e.Unary().Value)
+	}
+	return "..."
+}
+
+// implType is the class an impl block is for, like `vec<string>`, it is empty
+// for impls of the class with its own generic parameters.
+func implType(def *ast.Class, params []sema.Type) string {
+	if !slices.ContainsFunc(params, func(ty sema.Type) bool { return !ty.IsGeneric() }) {
+		return ""
+	}
+	names := make([]string, len(params))
+	for i, ty := range params {
+		names[i] = ty.String()
+	}
+	return def.Name.Raw + "<" + strings.Join(names, ", ") + ">"
+}
+
+func sortDocItems(items []*docItem) {
+	slices.SortStableFunc(items, compareDocItems)
+}
+
+func compareDocItems(a, b *docItem) int {
+	return cmp.Or(cmp.Compare(a.line, b.line), strings.Compare(a.Name, b.Name))
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+)
+
+// Anchor is the id of a module in the rendered page.
+func (m *docModule) Anchor() string {
+	return strings.NewReplacer("/", "-", ".", "-").Replace(m.Path)
+}
+
+func (m *docModule) empty() bool {
+	return len(m.Classes)+len(m.Traits)+len(m.Funcs)+len(m.Lets)+len(m.Aliases) == 0
+}
+
+// documented are the modules with public items.
+func (pkg *docPackage) documented() []*docModule {
+	var modules []*docModule
+	for _, module := range pkg.Modules {
+		if !module.empty() {
+			modules = append(modules, module)
+		}
+	}
+	return modules
+}
+
+func renderDocMarkdown(pkg *docPackage) []byte {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n", pkg.Name)
+	sb.WriteString("Public items by file. Each one is marked with the realm that has it, *server*, *client*, or *shared* for both.\n\n")
+	modules := pkg.documented()
+	for _, module := range modules {
+		fmt.Fprintf(&sb, "- [%s](#%s)\n", module.Path, module.Anchor())
+	}
+
+	item := func(level int, item *docItem) {
+		fmt.Fprintf(&sb, "\n%s `%s`\n\n", strings.Repeat("#", level), item.Name)
+		fmt.Fprintf(&sb, "```gluax\n%s\n```\n\n*%s*\n", item.Signature, item.RealmLabel())
+		if item.Doc != "" {
+			fmt.Fprintf(&sb, "\n%s\n", item.Doc)
+		}
+	}
+	section := func(title string, items []*docItem) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintf(&sb, "\n**%s**\n", title)
+		for _, it := range items {
+			item(4, it)
+		}
+	}
+	names := func(title string, names []string) {
+		if len(names) > 0 {
+			fmt.Fprintf(&sb, "\n**%s:** `%s`\n", title, strings.Join(names, "`, `"))
+		}
+	}
+
+	for _, module := range modules {
+		fmt.Fprintf(&sb, "\n<a id=\"%s\"></a>\n\n## %s\n", module.Anchor(), module.Path)
+		for _, class := range module.Classes {
+			item(3, &class.docItem)
+			section("Fields", class.Fields)
+			section("Methods", class.Methods)
+			names("Implements", class.Implements)
+		}
+		for _, trait := range module.Traits {
+			item(3, &trait.docItem)
+			section("Methods", trait.Methods)
+			names("Implemented by", trait.Implementors)
+		}
+		for _, items := range [][]*docItem{module.Funcs, module.Lets, module.Aliases} {
+			for _, it := range items {
+				item(3, it)
+			}
+		}
+	}
+	return []byte(sb.String())
+}
+
+func renderDocHTML(pkg *docPackage) ([]byte, error) {
+	var buf bytes.Buffer
+	err := docHTML.Execute(&buf, struct {
+		Name    string
+		Modules []*docModule
+	}{pkg.Name, pkg.documented()})
+	return buf.Bytes(), err
+}
+
+var docHTML = template.Must(template.New("doc").Funcs(template.FuncMap{
+	"members": func(title string, items []*docItem) any {
+		return struct {
+			Title string
+			Items []*docItem
+		}{title, items}
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}}</title>
+<style>
+body { font-family: sans-serif; max-width: 60em; margin: 2em auto; padding: 0 1em; line-height: 1.5; }
+pre { background: #f4f4f4; padding: .5em 1em; overflow-x: auto; }
+.item { margin: 1em 0; }
+.members { margin-left: 2em; }
+.realm { font-size: .8em; padding: .1em .5em; border-radius: .3em; background: #ddd; }
+.realm.server { background: #bfdbfe; }
+.realm.client { background: #fde68a; }
+.doc { white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>{{.Name}}</h1>
+<p>Public items by file. Each one is marked with the realm that has it, server, client, or shared for both.</p>
+<ul>
+{{- range .Modules}}
+<li><a href="#{{.Anchor}}">{{.Path}}</a></li>
+{{- end}}
+</ul>
+{{- define "item"}}
+<div class="item">
+<pre><code>{{.Signature}}</code></pre>
+<span class="realm {{.RealmLabel}}">{{.RealmLabel}}</span>
+{{- if .Doc}}
+<p class="doc">{{.Doc}}</p>
+{{- end}}
+</div>
+{{- end}}
+{{- define "members"}}
+{{- if .Items}}
+<div class="members">
+<h4>{{.Title}}</h4>
+{{- range .Items}}{{template "item" .}}{{end}}
+</div>
+{{- end}}
+{{- end}}
+{{- range .Modules}}
+<h2 id="{{.Anchor}}">{{.Path}}</h2>
+{{- range .Classes}}
+<h3>{{.Name}}</h3>
+{{- template "item" .}}
+{{- template "members" (members "Fields" .Fields)}}
+{{- template "members" (members "Methods" .Methods)}}
+{{- if .Implements}}
+<p class="members">Implements {{range $i, $t := .Implements}}{{if $i}}, {{end}}<code>{{$t}}</code>{{end}}</p>
+{{- end}}
+{{- end}}
+{{- range .Traits}}
+<h3>{{.Name}}</h3>
+{{- template "item" .}}
+{{- template "members" (members "Methods" .Methods)}}
+{{- if .Implementors}}
+<p class="members">Implemented by {{range $i, $t := .Implementors}}{{if $i}}, {{end}}<code>{{$t}}</code>{{end}}</p>
+{{- end}}
+{{- end}}
+{{- range .Funcs}}
+<h3>{{.Name}}</h3>
+{{- template "item" .}}
+{{- end}}
+{{- range .Lets}}
+<h3>{{.Name}}</h3>
+{{- template "item" .}}
+{{- end}}
+{{- range .Aliases}}
+<h3>{{.Name}}</h3>
+{{- template "item" .}}
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
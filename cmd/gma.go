@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// .gma archives, the format gmad writes and the workshop takes:
+//
+//	"GMAD" version:u8 steamid:u64 timestamp:u64 required:"\0"
+//	title\0 description\0 author\0 addon_version:i32
+//	{ number:u32 path\0 size:i64 crc:u32 }... 0:u32
+//	file contents... crc:u32
+//
+// Integers are little endian, description is a json object with the
+// description, type and tags of addon.json.
+
+const (
+	gmaIdent   = "GMAD"
+	gmaVersion = 3
+)
+
+// files the workshop accepts, `*` matches anything, `/` included
+var gmaWhitelist = []string{
+	"lua/*.lua",
+	"scenes/*.vcd",
+	"particles/*.pcf",
+	"resource/fonts/*.ttf",
+	"scripts/vehicles/*.txt",
+	"resource/localization/*/*.properties",
+	"maps/*.bsp",
+	"maps/*.lmp",
+	"maps/*.nav",
+	"maps/*.ain",
+	"maps/thumb/*.png",
+	"sound/*.wav",
+	"sound/*.mp3",
+	"sound/*.ogg",
+	"materials/*.vmt",
+	"materials/*.vtf",
+	"materials/*.png",
+	"materials/*.jpg",
+	"materials/*.jpeg",
+	"materials/colorcorrection/*.raw",
+	"models/*.mdl",
+	"models/*.vtx",
+	"models/*.phy",
+	"models/*.ani",
+	"models/*.vvd",
+	"gamemodes/*/*.txt",
+	"gamemodes/*/*.fgd",
+	"gamemodes/*/logo.png",
+	"gamemodes/*/icon24.png",
+	"gamemodes/*/gamemode/*.lua",
+	"gamemodes/*/entities/effects/*.lua",
+	"gamemodes/*/entities/weapons/*.lua",
+	"gamemodes/*/entities/entities/*.lua",
+	"gamemodes/*/backgrounds/*.png",
+	"gamemodes/*/backgrounds/*.jpg",
+	"gamemodes/*/backgrounds/*.jpeg",
+	"gamemodes/*/content/models/*.mdl",
+	"gamemodes/*/content/models/*.vtx",
+	"gamemodes/*/content/models/*.phy",
+	"gamemodes/*/content/models/*.ani",
+	"gamemodes/*/content/models/*.vvd",
+	"gamemodes/*/content/materials/*.vmt",
+	"gamemodes/*/content/materials/*.vtf",
+	"gamemodes/*/content/materials/*.png",
+	"gamemodes/*/content/materials/*.jpg",
+	"gamemodes/*/content/materials/*.jpeg",
+	"gamemodes/*/content/scenes/*.vcd",
+	"gamemodes/*/content/particles/*.pcf",
+	"gamemodes/*/content/resource/fonts/*.ttf",
+	"gamemodes/*/content/scripts/vehicles/*.txt",
+	"gamemodes/*/content/resource/localization/*/*.properties",
+	"gamemodes/*/content/maps/*.bsp",
+	"gamemodes/*/content/maps/*.nav",
+	"gamemodes/*/content/maps/*.ain",
+	"gamemodes/*/content/maps/thumb/*.png",
+	"gamemodes/*/content/sound/*.wav",
+	"gamemodes/*/content/sound/*.mp3",
+	"gamemodes/*/content/sound/*.ogg",
+	"data_static/*.txt",
+	"data_static/*.dat",
+	"data_static/*.json",
+	"data_static/*.xml",
+	"data_static/*.csv",
+	"shaders/*.vcs",
+}
+
+// addon.json of an addon, only what goes into the archive
+type gmaAddon struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Type        string   `json:"type"`
+	Tags        []string `json:"tags"`
+	Ignore      []string `json:"ignore"`
+}
+
+type gmaFile struct {
+	path string // relative to the addon, lower case and with `/`
+	data []byte
+}
+
+// readAddon reads addon.json and the files of the addon in dir, files that
+// match its ignore patterns are left out, ones the workshop doesn't take are
+// an error.
+func readAddon(dir string) (gmaAddon, []gmaFile, error) {
+	var addon gmaAddon
+	data, err := os.ReadFile(filepath.Join(dir, "addon.json"))
+	if err != nil {
+		return addon, nil, err
+	}
+	if err := json.Unmarshal(data, &addon); err != nil {
+		return addon, nil, fmt.Errorf("addon.json: %w", err)
+	}
+	if addon.Title == "" {
+		return addon, nil, fmt.Errorf("addon.json: missing title")
+	}
+
+	var (
+		files      []gmaFile
+		disallowed []string
+	)
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = strings.ToLower(filepath.ToSlash(rel))
+		if rel == "addon.json" || slices.ContainsFunc(addon.Ignore, func(pattern string) bool {
+			return wildcardMatch(strings.ToLower(pattern), rel)
+		}) {
+			return nil
+		}
+		if !slices.ContainsFunc(gmaWhitelist, func(pattern string) bool {
+			return wildcardMatch(pattern, rel)
+		}) {
+			disallowed = append(disallowed, rel)
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		files = append(files, gmaFile{rel, data})
+		return nil
+	})
+	if err != nil {
+		return addon, nil, err
+	}
+	if len(disallowed) > 0 {
+		return addon, nil, fmt.Errorf("the workshop doesn't allow these files, add them to the ignore list of [addon] in gluax.toml:\n  %s", strings.Join(disallowed, "\n  "))
+	}
+	slices.SortFunc(files, func(a, b gmaFile) int { return strings.Compare(a.path, b.path) })
+	return addon, files, nil
+}
+
+// wildcardMatch matches s against a pattern where `*` is any run of
+// characters and `?` any one character.
+func wildcardMatch(pattern, s string) bool {
+	star, backtrack := -1, 0
+	i, j := 0, 0
+	for j < len(s) {
+		switch {
+		case i < len(pattern) && (pattern[i] == '?' || pattern[i] == s[j]):
+			i++
+			j++
+		case i < len(pattern) && pattern[i] == '*':
+			star, backtrack = i, j
+			i++
+		case star >= 0:
+			backtrack++
+			i, j = star+1, backtrack
+		default:
+			return false
+		}
+	}
+	for i < len(pattern) && pattern[i] == '*' {
+		i++
+	}
+	return i == len(pattern)
+}
+
+// writeGMA encodes an addon into a .gma archive.
+func writeGMA(addon gmaAddon, files []gmaFile, timestamp uint64) ([]byte, error) {
+	tags := addon.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	description, err := json.Marshal(struct {
+		Description string   `json:"description"`
+		Type        string   `json:"type"`
+		Tags        []string `json:"tags"`
+	}{addon.Description, addon.Type, tags})
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+	writeString := func(s string) {
+		buf.WriteString(s)
+		buf.WriteByte(0)
+	}
+
+	buf.WriteString(gmaIdent)
+	buf.WriteByte(gmaVersion)
+	buf.Write(le.AppendUint64(nil, 0)) // steamid, unused
+	buf.Write(le.AppendUint64(nil, timestamp))
+	buf.WriteByte(0) // no required content
+	writeString(addon.Title)
+	writeString(string(description))
+	writeString("Author Name")         // unused
+	buf.Write(le.AppendUint32(nil, 1)) // addon version, unused
+
+	for i, file := range files {
+		if strings.IndexByte(file.path, 0) >= 0 {
+			return nil, fmt.Errorf("invalid file name %q", file.path)
+		}
+		buf.Write(le.AppendUint32(nil, uint32(i+1)))
+		writeString(file.path)
+		buf.Write(le.AppendUint64(nil, uint64(len(file.data))))
+		buf.Write(le.AppendUint32(nil, crc32.ChecksumIEEE(file.data)))
+	}
+	buf.Write(le.AppendUint32(nil, 0))
+
+	for _, file := range files {
+		buf.Write(file.data)
+	}
+	buf.Write(le.AppendUint32(nil, crc32.ChecksumIEEE(buf.Bytes())))
+	return buf.Bytes(), nil
+}
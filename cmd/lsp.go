@@ -1,11 +1,19 @@
 package main
 
-import "github.com/gluax-lang/gluax/cmd/lsp"
+import (
+	"github.com/gluax-lang/gluax/cmd/lsp"
+	"github.com/gluax-lang/gluax/frontend/sema"
+)
 
 type LspCmd struct {
 	Stdio bool `help:"(internal) LSP clients pass this flag. Safe to ignore." name:"stdio"`
+	ProfileFlags
 }
 
 func (l *LspCmd) Run() error {
-	return lsp.RunLSP()
+	var options sema.CompileOptions
+	if err := l.apply(&options); err != nil {
+		return err
+	}
+	return lsp.RunLSP(options)
 }
@@ -3,6 +3,7 @@ package lsp
 import (
 	"fmt"
 
+	"github.com/gluax-lang/gluax/frontend/ast"
 	"github.com/gluax-lang/lsp"
 )
 
@@ -19,6 +20,9 @@ func (h *Handler) Hover(p *lsp.HoverParams) (*lsp.Hover, error) {
 	}
 
 	content := fmt.Sprintf("```gluax\n%s\n```\n", (*sym).LSPString())
+	if doc := ast.DocOf(*sym); doc != "" {
+		content += "\n---\n\n" + doc + "\n"
+	}
 
 	return &lsp.Hover{
 		Contents: lsp.MarkupContent{
@@ -16,8 +16,12 @@ import (
 	"github.com/gluax-lang/lsp"
 )
 
-func RunLSP() error {
-	return NewHandler().Serve(context.Background())
+// RunLSP serves the LSP on stdio, projects are analyzed with the profile,
+// release mode and defines of options.
+func RunLSP(options sema.CompileOptions) error {
+	h := NewHandler()
+	h.options = options
+	return h.Serve(context.Background())
 }
 
 type FileAnalysis struct {
@@ -31,6 +35,7 @@ type Handler struct {
 	mu               sync.Mutex
 	workspace        string
 	lastProjAnalysis *sema.ProjectAnalysis
+	options          sema.CompileOptions // profile flags of `gluax lsp`
 }
 
 func NewHandler() *Handler {
@@ -84,10 +89,9 @@ func (h *Handler) Initialized() error {
 
 func (h *Handler) compileProject() *sema.ProjectAnalysis {
 	overrides := h.fileCache
-	options := sema.CompileOptions{
-		Workspace:    h.workspace,
-		VirtualFiles: overrides,
-	}
+	options := h.options
+	options.Workspace = h.workspace
+	options.VirtualFiles = overrides
 	pAnalysis, err := sema.AnalyzeProject(options)
 	if err != nil {
 		log.Printf("error analyzing project: %v", err)
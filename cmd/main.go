@@ -17,8 +17,12 @@ func main() {
 
 type CLI struct {
 	Build   BuildCmd   `cmd:"" help:"Build the project." aliases:"compile"`
+	Package PackageCmd `cmd:"" help:"Pack the addon of a --target=addon build into a .gma for the workshop."`
 	New     NewCmd     `cmd:"" help:"Create a new project."`
+	Init    InitCmd    `cmd:"" help:"Create a project in an existing directory."`
 	Check   CheckCmd   `cmd:"" help:"Check the project for errors."`
+	Doc     DocCmd     `cmd:"" help:"Generate documentation from the doc comments of a package."`
+	Trace   TraceCmd   `cmd:"" help:"Map a lua stack trace back to gluax source."`
 	Lsp     LspCmd     `cmd:"" help:"Run the LSP server."`
 	Version VersionCmd `cmd:"" help:"Show version."`
 }
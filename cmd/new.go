@@ -1,37 +1,149 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/gluax-lang/gluax/frontend"
+	"github.com/gluax-lang/gluax/frontend/lexer"
 )
 
+// ProjectFlags pick what a new project starts with.
+type ProjectFlags struct {
+	Lib      bool   `help:"Create a library, its entry point is src/lib.gluax."`
+	Template string `help:"Start from a template: ${enum}." enum:"none,addon,gamemode,entity,weapon" default:"none"`
+}
+
 type NewCmd struct {
 	Name string `arg:"" required:"" help:"Name of the new project."`
+	ProjectFlags
 }
 
+// Run creates the project in a new directory named after it.
 func (n *NewCmd) Run() error {
-	projectDir := n.Name
-	if err := os.MkdirAll(filepath.Join(projectDir, "src"), 0755); err != nil {
+	if err := validateProjectName(n.Name); err != nil {
 		return err
 	}
-
-	// .gitignore
-	gitignoreContent := "out/\n"
-	if err := os.WriteFile(filepath.Join(projectDir, ".gitignore"), []byte(gitignoreContent), 0644); err != nil {
+	entries, err := os.ReadDir(n.Name)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("%s already exists and is not empty, use `gluax init` in it instead", n.Name)
+	}
+	if err := n.ProjectFlags.create(n.Name, n.Name); err != nil {
 		return err
 	}
+	fmt.Printf("created %s\n", n.Name)
+	return nil
+}
+
+type InitCmd struct {
+	Path string `help:"Path to the project directory." short:"p" default:"."`
+	Name string `help:"Name of the project, the name of the directory by default."`
+	ProjectFlags
+}
 
-	// gluax.toml
-	tomlContent := "name = \"" + n.Name + "\"\nversion = \"0.1\"\n"
-	if err := os.WriteFile(filepath.Join(projectDir, "gluax.toml"), []byte(tomlContent), 0644); err != nil {
+// Run creates a project in an existing directory, files that are already
+// there are kept.
+func (c *InitCmd) Run() error {
+	absPath, err := filepath.Abs(c.Path)
+	if err != nil {
+		return err
+	}
+	name := c.Name
+	if name == "" {
+		name = filepath.Base(absPath)
+		if err := validateProjectName(name); err != nil {
+			return fmt.Errorf("%w, pick another one with --name", err)
+		}
+	} else if err := validateProjectName(name); err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(absPath, "gluax.toml")); err == nil {
+		return fmt.Errorf("%s already has a gluax.toml", c.Path)
+	}
+	if err := c.ProjectFlags.create(absPath, name); err != nil {
 		return err
 	}
+	fmt.Printf("created %s in %s\n", name, c.Path)
+	return nil
+}
+
+// validateProjectName checks that name can be used to import the project.
+func validateProjectName(name string) error {
+	switch {
+	case !lexer.IsValidIdent(name):
+		return fmt.Errorf("invalid project name %q, it is used as an import name so it has to be an identifier, like my_addon", name)
+	case lexer.IsKeyword(name):
+		return fmt.Errorf("invalid project name %q, it is a keyword", name)
+	case strings.HasPrefix(name, frontend.PreservedPrefix):
+		return fmt.Errorf("invalid project name %q, names can't start with %s", name, frontend.PreservedPrefix)
+	}
+	return nil
+}
 
-	// src/main.gluax
-	mainGluaxContent := "func main() {\n\n}\n"
-	if err := os.WriteFile(filepath.Join(projectDir, "src", "main.gluax"), []byte(mainGluaxContent), 0644); err != nil {
+// create writes the files of the project to dir, .gitignore gets `out/`
+// added if it exists and the other files are only written if they don't.
+func (f *ProjectFlags) create(dir, name string) error {
+	if f.Lib && f.Template != "none" {
+		return fmt.Errorf("--lib can't be used with --template, templates are addons")
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "src"), 0755); err != nil {
+		return err
+	}
+	if err := addToGitignore(filepath.Join(dir, ".gitignore"), "out/"); err != nil {
 		return err
 	}
 
+	files := projectFiles(name, f.Lib, f.Template)
+	for _, path := range slices.Sorted(maps.Keys(files)) {
+		err := writeNewFile(filepath.Join(dir, filepath.FromSlash(path)), files[path])
+		if errors.Is(err, fs.ErrExist) {
+			fmt.Printf("%s already exists, leaving it as it is\n", path)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// addToGitignore adds line to the .gitignore at path unless it has it.
+func addToGitignore(path, line string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	content := string(data)
+	if slices.Contains(strings.Split(content, "\n"), line) {
+		return nil
+	}
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return os.WriteFile(path, []byte(content+line+"\n"), 0644)
+}
+
+// writeNewFile writes a file that doesn't exist yet.
+func writeNewFile(path, content string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(content); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gluax-lang/gluax/frontend"
+)
+
+type PackageCmd struct {
+	Path   string `help:"Path to the project directory." short:"p" default:"."`
+	Output string `help:"Where to write the archive, out/<name>.gma by default." short:"o"`
+}
+
+// Run packs the addon of a `--target=addon` build into a .gma archive.
+func (c *PackageCmd) Run() error {
+	absPath, err := filepath.Abs(c.Path)
+	if err != nil {
+		return err
+	}
+	tomlContent, err := os.ReadFile(filepath.Join(absPath, "gluax.toml"))
+	if err != nil {
+		return fmt.Errorf("failed to load gluax.toml: %w", err)
+	}
+	config, err := frontend.HandleGluaxToml(string(tomlContent))
+	if err != nil {
+		return fmt.Errorf("failed to load gluax.toml: %w", err)
+	}
+
+	layout := outputLayout{target: TargetAddon, outDir: filepath.Join(absPath, "out"), name: strings.ToLower(config.Name)}
+	addonDir := layout.addonDir()
+	if _, err := os.Stat(addonDir); err != nil {
+		return fmt.Errorf("no addon in %s, run `gluax build --target=addon` first", addonDir)
+	}
+	addon, files, err := readAddon(addonDir)
+	if err != nil {
+		return err
+	}
+	data, err := writeGMA(addon, files, gmaTimestamp())
+	if err != nil {
+		return err
+	}
+
+	output := c.Output
+	if output == "" {
+		output = filepath.Join(layout.outDir, layout.name+".gma")
+	}
+	if err := os.WriteFile(output, data, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("packed %d files into %s (%d bytes)\n", len(files), output, len(data))
+	return nil
+}
+
+// gmaTimestamp is the time written into the archive, SOURCE_DATE_EPOCH
+// overrides it so that packaging the same build gives the same file.
+func gmaTimestamp() uint64 {
+	if epoch, err := strconv.ParseUint(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+		return epoch
+	}
+	return uint64(time.Now().Unix())
+}
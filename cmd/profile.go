@@ -0,0 +1,29 @@
+package main
+
+import (
+	"github.com/gluax-lang/gluax/frontend/sema"
+)
+
+// ProfileFlags pick the profile and macros a project is analyzed with.
+type ProfileFlags struct {
+	Release bool     `help:"Build in release mode, same as --profile=release." short:"r"`
+	Profile string   `help:"Profile of gluax.toml to use, dev by default."`
+	Define  []string `help:"Define a preprocessor macro." short:"D" placeholder:"NAME[=value]" sep:"none"`
+}
+
+func (f *ProfileFlags) apply(options *sema.CompileOptions) error {
+	options.Release = f.Release
+	options.Profile = f.Profile
+	if len(f.Define) == 0 {
+		return nil
+	}
+	options.Defines = make(map[string]string, len(f.Define))
+	for _, define := range f.Define {
+		name, value, err := sema.ParseDefine(define)
+		if err != nil {
+			return err
+		}
+		options.Defines[name] = value
+	}
+	return nil
+}
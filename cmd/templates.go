@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// projectFiles are the files of a new project, by path relative to it.
+// `{name}` in the templates is replaced with the name of the project.
+func projectFiles(name string, lib bool, template string) map[string]string {
+	var toml strings.Builder
+	fmt.Fprintf(&toml, "name = %q\nversion = \"0.1\"\n", name)
+	if lib {
+		toml.WriteString("lib = true\n")
+	}
+
+	files := make(map[string]string)
+	switch {
+	case lib:
+		files["src/lib.gluax"] = libTemplate
+	case template == "none" || template == "":
+		files["src/main.gluax"] = mainTemplate
+	default:
+		t := addonTemplates[template]
+		files["src/main.gluax"] = t.main
+		toml.WriteString("\n[addon]\n")
+		fmt.Fprintf(&toml, "title = %q\n", name)
+		if t.addonType != "" {
+			fmt.Fprintf(&toml, "type = %q\n", t.addonType)
+		}
+	}
+	files["gluax.toml"] = toml.String()
+
+	for path, content := range files {
+		files[path] = strings.ReplaceAll(content, "{name}", name)
+	}
+	return files
+}
+
+const mainTemplate = `func main() {
+
+}
+`
+
+const libTemplate = `pub func hello() -> string {
+    return "hello from {name}";
+}
+`
+
+// templates of `gluax new --template`, they are built with --target=addon
+var addonTemplates = map[string]struct {
+	addonType string // type of addon.json, the default one when empty
+	main      string
+}{
+	"addon": {"", `// runs when the addon is loaded
+func main() {
+    print("{name} loaded");
+}
+
+#[hook = "PlayerInitialSpawn"]
+func on_join(ply: Player) {
+    print(ply.name(), "joined");
+}
+`},
+
+	// addon builds of gamemodes write gamemodes/<name>/, deriving from base
+	"gamemode": {"gamemode", `func main() {
+    print("{name} loaded");
+}
+
+#[hook = "PlayerSpawn"]
+func on_spawn(ply: Player) {
+    ply.set_health(100);
+}
+
+#[hook = "PlayerDeath"]
+func on_death(victim: Player, inflictor: Entity, attacker: Entity) {
+    print(victim.name(), "died");
+}
+`},
+
+	"entity": {"entity", `// the table garry's mod makes the entity from, see scripted_ents.Register
+#[named_fields]
+class EntityTable {
+    Type: string,
+    Base: string,
+    PrintName: string,
+    Category: string,
+    Spawnable: bool,
+    Initialize: func(Entity),
+}
+
+#[global = "scripted_ents.Register"]
+func register(ent: EntityTable, name: string);
+
+func initialize(ent: Entity) {
+    print("spawned {name}", ent.index());
+}
+
+func main() {
+    register(EntityTable {
+        Type: "anim",
+        Base: "base_anim",
+        PrintName: "{name}",
+        Category: "{name}",
+        Spawnable: true,
+        Initialize: initialize,
+    }, "{name}");
+}
+`},
+
+	"weapon": {"weapon", `// the table garry's mod makes the weapon from, see weapons.Register
+#[named_fields]
+class WeaponTable {
+    Base: string,
+    PrintName: string,
+    Category: string,
+    Spawnable: bool,
+    PrimaryAttack: func(Entity),
+    SecondaryAttack: func(Entity),
+}
+
+#[global = "weapons.Register"]
+func register(weapon: WeaponTable, name: string);
+
+func primary_attack(weapon: Entity) {
+    print("{name}: primary attack");
+}
+
+func secondary_attack(weapon: Entity) {
+    print("{name}: secondary attack");
+}
+
+func main() {
+    register(WeaponTable {
+        Base: "weapon_base",
+        PrintName: "{name}",
+        Category: "{name}",
+        Spawnable: true,
+        PrimaryAttack: primary_attack,
+        SecondaryAttack: secondary_attack,
+    }, "{name}");
+}
+`},
+}
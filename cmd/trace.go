@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	codegen "github.com/gluax-lang/gluax/backend"
+)
+
+type TraceCmd struct {
+	Path  string `help:"Path to the project directory." short:"p" default:"."`
+	Input string `arg:"" optional:"" help:"File containing the stack trace, reads stdin if omitted."`
+}
+
+// matches `lua/autorun/server/sv_name.lua:123`, the directory part is whatever
+// path the file was mounted at in game
+var luaPosRegex = regexp.MustCompile(`[^\s:'"()\[\]]*?([^\s:'"()\[\]/\\]+\.lua):(\d+)`)
+
+func (t *TraceCmd) Run() error {
+	absPath, err := filepath.Abs(t.Path)
+	if err != nil {
+		return err
+	}
+
+	var trace []byte
+	if t.Input == "" {
+		trace, err = io.ReadAll(os.Stdin)
+	} else {
+		trace, err = os.ReadFile(t.Input)
+	}
+	if err != nil {
+		return err
+	}
+
+	outDir := filepath.Join(absPath, "out")
+	maps := make(map[string]*codegen.SourceMap)
+	loadMap := func(fileName string) *codegen.SourceMap {
+		if sm, ok := maps[fileName]; ok {
+			return sm
+		}
+		var sm *codegen.SourceMap
+		if data, err := os.ReadFile(filepath.Join(outDir, fileName+".map")); err == nil {
+			sm = &codegen.SourceMap{}
+			if err := json.Unmarshal(data, sm); err != nil {
+				sm = nil
+			}
+		}
+		maps[fileName] = sm // cache misses as well
+		return sm
+	}
+
+	rewritten := luaPosRegex.ReplaceAllStringFunc(string(trace), func(match string) string {
+		sub := luaPosRegex.FindStringSubmatch(match)
+		sm := loadMap(sub[1])
+		if sm == nil {
+			return match
+		}
+		luaLine, err := strconv.Atoi(sub[2])
+		if err != nil {
+			return match
+		}
+		source, line, column, ok := sm.Lookup(luaLine)
+		if !ok {
+			return match
+		}
+		return fmt.Sprintf("%s:%d:%d", source, line, column)
+	})
+
+	_, err = os.Stdout.WriteString(rewritten)
+	return err
+}
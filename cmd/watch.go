@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	codegen "github.com/gluax-lang/gluax/backend"
+	"github.com/gluax-lang/gluax/common"
+	"github.com/gluax-lang/gluax/frontend/sema"
+)
+
+// editors save a file with a burst of events, they get collected for this long
+// before rebuilding
+const watchDebounce = 150 * time.Millisecond
+
+type buildWatcher struct {
+	b       *BuildCmd
+	options sema.CompileOptions
+	fs      *fsnotify.Watcher
+	src     string
+	toml    string
+
+	// files each realm was built from the last time
+	files map[sema.Realm]map[string]struct{}
+	// realms whose last build had errors or didn't happen
+	failed sema.Realm
+	// layout of the last build, nil until one got analyzed
+	layout *outputLayout
+}
+
+// watch builds the project, then rebuilds the realms that use the files that
+// change under src/ until interrupted. Changes to gluax.toml rebuild both.
+func (b *BuildCmd) watch(options sema.CompileOptions) error {
+	if b.VerifyReproducible {
+		return fmt.Errorf("--verify-reproducible can't be used with --watch")
+	}
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer fsw.Close()
+
+	options.Cache = sema.NewParseCache()
+	w := &buildWatcher{
+		b:       b,
+		options: options,
+		fs:      fsw,
+		src:     common.FilePathClean(filepath.Join(options.Workspace, "src")),
+		toml:    common.FilePathClean(filepath.Join(options.Workspace, "gluax.toml")),
+		files:   make(map[sema.Realm]map[string]struct{}),
+	}
+	// gluax.toml is watched through its directory, editors often replace the
+	// file instead of writing to it
+	if err := fsw.Add(options.Workspace); err != nil {
+		return err
+	}
+	if err := w.addDir(w.src); err != nil {
+		return err
+	}
+	if b.Target == TargetAddon {
+		for _, dir := range addonContentDirs {
+			if err := w.addDir(filepath.Join(options.Workspace, dir)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	w.rebuild(sema.RealmAll, nil)
+
+	var (
+		pending sema.Realm
+		content bool
+		changed []string
+		timer   = time.NewTimer(0)
+	)
+	<-timer.C
+	for {
+		select {
+		case <-interrupt:
+			return nil
+		case err := <-fsw.Errors:
+			fmt.Fprintf(os.Stderr, "watch error: %v\n", err)
+		case event := <-fsw.Events:
+			realms, isContent := w.handleEvent(event)
+			if realms == 0 && !isContent {
+				continue
+			}
+			pending |= realms
+			content = content || isContent
+			changed = append(changed, event.Name)
+			timer.Reset(watchDebounce)
+		case <-timer.C:
+			if pending != 0 {
+				w.rebuild(pending, changed)
+			} else if content {
+				w.syncContent(changed)
+			}
+			pending, content, changed = 0, false, nil
+		}
+	}
+}
+
+// addDir watches dir and every directory below it.
+func (w *buildWatcher) addDir(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return w.fs.Add(path)
+		}
+		return nil
+	})
+}
+
+// handleEvent returns the realms the change affects, and if it is a change
+// to the content copied into addons.
+func (w *buildWatcher) handleEvent(event fsnotify.Event) (sema.Realm, bool) {
+	if event.Op == fsnotify.Chmod {
+		return 0, false
+	}
+	path := common.FilePathClean(event.Name)
+	if path == w.toml {
+		return sema.RealmAll, false
+	}
+	inSrc, content := inDir(path, w.src), w.isContent(path)
+	if !inSrc && !content {
+		return 0, false
+	}
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			if err := w.addDir(event.Name); err != nil {
+				fmt.Fprintf(os.Stderr, "watch error: %v\n", err)
+			}
+		}
+	}
+	if content {
+		return 0, true
+	}
+	var realms sema.Realm
+	for realm, files := range w.files {
+		if _, ok := files[path]; ok {
+			realms |= realm
+		}
+	}
+	// a file no realm uses can only matter to a build that failed, by not
+	// being found for an import for example
+	if realms == 0 && strings.HasSuffix(path, ".gluax") {
+		realms = w.failed
+	}
+	return realms, false
+}
+
+func (w *buildWatcher) isContent(path string) bool {
+	if w.b.Target != TargetAddon {
+		return false
+	}
+	for _, dir := range addonContentDirs {
+		if inDir(path, common.FilePathClean(filepath.Join(w.options.Workspace, dir))) {
+			return true
+		}
+	}
+	return false
+}
+
+// inDir tells if the cleaned path is dir or is below it.
+func inDir(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+"/")
+}
+
+// syncContent copies the content directories again, without rebuilding.
+func (w *buildWatcher) syncContent(changed []string) {
+	if w.layout == nil {
+		return
+	}
+	fmt.Printf("\n%s changed, copying content\n", w.relPath(changed[0]))
+	if _, err := w.layout.finish(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+	}
+}
+
+func (w *buildWatcher) rebuild(realms sema.Realm, changed []string) {
+	if len(changed) > 0 {
+		fmt.Printf("\n%s changed, rebuilding\n", w.relPath(changed[0]))
+	}
+	start := time.Now()
+	defer func() {
+		fmt.Printf("[%s] done in %s, watching for changes\n", time.Now().Format(time.TimeOnly), time.Since(start).Round(time.Millisecond))
+	}()
+
+	options := w.options
+	options.Realms = realms
+	pAnalysis, err := sema.AnalyzeProject(options)
+	if err != nil {
+		w.failed |= realms
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return
+	}
+	if realms == sema.RealmAll {
+		// the other realm keeps its files otherwise
+		options.Cache.Prune()
+	}
+
+	layout, err := newOutputLayout(w.b.Target, options.Workspace, pAnalysis.Config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return
+	}
+	w.layout = layout
+
+	builds := []struct {
+		realm    sema.Realm
+		label    string
+		file     string
+		files    map[string]*sema.Analysis
+		generate func(*sema.ProjectAnalysis) codegen.Output
+	}{
+		{sema.RealmServer, "SERVER", layout.serverFile(), pAnalysis.ServerFiles(), codegen.GenerateServer},
+		{sema.RealmClient, "CLIENT", layout.clientFile(), pAnalysis.ClientFiles(), codegen.GenerateClient},
+	}
+	for _, build := range builds {
+		if realms&build.realm == 0 {
+			continue
+		}
+		w.failed |= build.realm
+		files := make(map[string]struct{}, len(build.files))
+		for path := range build.files {
+			files[path] = struct{}{}
+		}
+		w.files[build.realm] = files
+
+		if errors := printDiagnostics(build.label, build.files); errors > 0 {
+			fmt.Printf("%s: %d errors, %s not updated\n", build.label, errors, build.file)
+			continue
+		}
+		out := build.generate(pAnalysis)
+		if len(out.Errors) > 0 {
+			for _, e := range out.Errors {
+				fmt.Fprintf(os.Stderr, "error: %s: %s\n", spanPos(options.Workspace, e.Span), e.Message)
+			}
+			continue
+		}
+		written, err := layout.writeRealm(build.file, out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			continue
+		}
+		w.failed &^= build.realm
+		if written {
+			fmt.Printf("%s: wrote %s\n", build.label, w.relPath(filepath.Join(layout.luaDir(), build.file)))
+		} else {
+			fmt.Printf("%s: %s unchanged\n", build.label, w.relPath(filepath.Join(layout.luaDir(), build.file)))
+		}
+	}
+	if changed, err := layout.finish(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+	} else if changed {
+		fmt.Printf("wrote addon files to %s\n", w.relPath(layout.addonDir()))
+	}
+
+	if w.b.PrintUnused && realms&sema.RealmServer != 0 && w.failed&sema.RealmServer == 0 {
+		printUnused(pAnalysis, options.Workspace)
+	}
+	if w.b.InlineReport && realms&sema.RealmServer != 0 && w.failed&sema.RealmServer == 0 {
+		printInlineReport(pAnalysis, options.Workspace)
+	}
+}
+
+func (w *buildWatcher) relPath(path string) string {
+	if rel, err := filepath.Rel(w.options.Workspace, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
@@ -13,6 +13,7 @@ type Ast struct {
 	Lets        []*Let
 	Classes     []*Class
 	Traits      []*Trait
+	TypeAliases []*TypeAlias
 
 	TokenStream []lexer.Token
 	Code        string
@@ -44,6 +44,8 @@ const (
 	BinaryOpMul
 	// BinaryOpDiv is `/`
 	BinaryOpDiv
+	// BinaryOpIntDiv is `~/`, `//` is already taken by comments
+	BinaryOpIntDiv
 	// BinaryOpMod is `%`
 	BinaryOpMod
 	// BinaryOpExponent is `**`
@@ -4,7 +4,8 @@ import "github.com/gluax-lang/gluax/common"
 
 type Block struct {
 	Stmts  []Stmt
-	stopAt int // index of the unreachable statement to stop at
+	Cfgs   []*Cfg // the `#[cfg(...)]` of each statement, nil if none has one
+	stopAt int    // index in ActiveStmts of the unreachable statement to stop at
 	sem    SemType
 	span   common.Span
 }
@@ -34,3 +35,17 @@ func (b *Block) SetStopAt(idx int) {
 func (b Block) StopAt() int {
 	return b.stopAt
 }
+
+// ActiveStmts is the statements that `#[cfg(...)]` didn't turn off.
+func (b *Block) ActiveStmts() []Stmt {
+	if b.Cfgs == nil {
+		return b.Stmts
+	}
+	stmts := make([]Stmt, 0, len(b.Stmts))
+	for i, stmt := range b.Stmts {
+		if b.Cfgs[i].Active() {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts
+}
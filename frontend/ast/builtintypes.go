@@ -18,6 +18,10 @@ pub class bool { _priv: nil }
 #[sealed]
 pub class number { _priv: nil }
 
+#[no_metatable]
+#[sealed]
+pub class int: number {}
+
 #[no_metatable]
 #[sealed]
 pub class string { _priv: nil }
@@ -53,6 +57,10 @@ pub trait Ord {
 	func lt(self, other: Self) -> bool;
 	func le(self, other: Self) -> bool;
 }
+
+pub trait From<E> {
+	func from(err: E) -> Self;
+}
 `
 
 var builtin = map[string]struct{}{
@@ -60,6 +68,7 @@ var builtin = map[string]struct{}{
 	"any":     {},
 	"bool":    {},
 	"number":  {},
+	"int":     {},
 	"string":  {},
 	"vec":     {},
 	"map":     {},
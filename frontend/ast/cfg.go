@@ -0,0 +1,80 @@
+package ast
+
+import "github.com/gluax-lang/gluax/common"
+
+type CfgKind uint8
+
+const (
+	CfgServer  CfgKind = iota // `server`
+	CfgClient                 // `client`
+	CfgFeature                // `feature = "name"`
+	CfgNot                    // `not(a)`
+	CfgAll                    // `all(a, b)`
+	CfgAny                    // `any(a, b)`
+)
+
+// CfgPredicate is the predicate of a `#[cfg(...)]` attribute.
+type CfgPredicate struct {
+	Kind    CfgKind
+	Feature string         // for CfgFeature
+	Args    []CfgPredicate // for CfgNot, CfgAll and CfgAny
+	Span    common.Span
+}
+
+// Holds tells if the predicate is true in realm ("server" or "client") with
+// features defined.
+func (c CfgPredicate) Holds(realm string, features map[string]string) bool {
+	switch c.Kind {
+	case CfgServer:
+		return realm == "server"
+	case CfgClient:
+		return realm == "client"
+	case CfgFeature:
+		_, defined := features[c.Feature]
+		return defined
+	case CfgNot:
+		return !c.Args[0].Holds(realm, features)
+	case CfgAll:
+		for _, arg := range c.Args {
+			if !arg.Holds(realm, features) {
+				return false
+			}
+		}
+		return true
+	case CfgAny:
+		for _, arg := range c.Args {
+			if arg.Holds(realm, features) {
+				return true
+			}
+		}
+		return false
+	}
+	panic("unreachable")
+}
+
+// Cfg is the `#[cfg(...)]` attributes of a node, nodes without any have a nil
+// Cfg. Nodes they turn off stay in the tree, so the LSP still sees them, but
+// the analysis marks them for its state and nothing after it looks at them.
+type Cfg struct {
+	Predicates []CfgPredicate
+	Inactive   bool // set by Resolve
+}
+
+// Active tells if the node is on in the state being analysed.
+func (c *Cfg) Active() bool {
+	return c == nil || !c.Inactive
+}
+
+// Resolve turns the node off unless all of its predicates hold.
+func (c *Cfg) Resolve(realm string, features map[string]string) {
+	if c == nil {
+		return
+	}
+	c.Inactive = false
+	for _, pred := range c.Predicates {
+		if !pred.Holds(realm, features) {
+			c.Inactive = true
+			return
+		}
+	}
+}
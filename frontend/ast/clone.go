@@ -0,0 +1,90 @@
+package ast
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// Clone returns a deep copy of a tree. The analysis annotates a tree in place,
+// so a parsed tree that is analysed more than once has to be cloned before it
+// gets annotated. Nodes shared inside the tree stay shared in the copy.
+func (a *Ast) Clone() *Ast {
+	c := cloner{seen: make(map[clonedPtr]reflect.Value)}
+	return c.clone(reflect.ValueOf(a)).Interface().(*Ast)
+}
+
+type clonedPtr struct {
+	ty  reflect.Type
+	ptr uintptr
+}
+
+type cloner struct {
+	seen map[clonedPtr]reflect.Value
+}
+
+// writable makes the unexported fields of an addressable value usable
+func writable(v reflect.Value) reflect.Value {
+	return reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem()
+}
+
+func (c *cloner) clone(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		key := clonedPtr{v.Type(), v.Pointer()}
+		if n, ok := c.seen[key]; ok {
+			return n
+		}
+		n := reflect.New(v.Type().Elem())
+		c.seen[key] = n
+		n.Elem().Set(c.clone(v.Elem()))
+		return n
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		n := reflect.New(v.Type()).Elem()
+		n.Set(c.clone(v.Elem()))
+		return n
+	case reflect.Struct:
+		if !v.CanAddr() {
+			tmp := reflect.New(v.Type()).Elem()
+			tmp.Set(v)
+			v = tmp
+		}
+		n := reflect.New(v.Type()).Elem()
+		for i := range v.NumField() {
+			writable(n.Field(i)).Set(c.clone(writable(v.Field(i))))
+		}
+		return n
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		n := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := range v.Len() {
+			n.Index(i).Set(c.clone(v.Index(i)))
+		}
+		return n
+	case reflect.Array:
+		n := reflect.New(v.Type()).Elem()
+		for i := range v.Len() {
+			n.Index(i).Set(c.clone(v.Index(i)))
+		}
+		return n
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		n := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			n.SetMapIndex(c.clone(iter.Key()), c.clone(iter.Value()))
+		}
+		return n
+	default:
+		return v
+	}
+}
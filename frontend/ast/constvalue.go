@@ -0,0 +1,124 @@
+package ast
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type ConstKind uint8
+
+const (
+	ConstNil ConstKind = iota
+	ConstBool
+	ConstNumber
+	ConstString
+)
+
+// ConstValue is the result of evaluating an expression at compile time.
+type ConstValue struct {
+	Kind   ConstKind
+	Bool   bool
+	Number float64
+	String string
+}
+
+func NewConstBool(b bool) *ConstValue {
+	return &ConstValue{Kind: ConstBool, Bool: b}
+}
+
+func NewConstNumber(n float64) *ConstValue {
+	return &ConstValue{Kind: ConstNumber, Number: n}
+}
+
+func NewConstString(s string) *ConstValue {
+	return &ConstValue{Kind: ConstString, String: s}
+}
+
+func (c *ConstValue) Equals(other *ConstValue) bool {
+	if c.Kind != other.Kind {
+		return false
+	}
+	switch c.Kind {
+	case ConstBool:
+		return c.Bool == other.Bool
+	case ConstNumber:
+		return c.Number == other.Number
+	case ConstString:
+		return c.String == other.String
+	default:
+		return true
+	}
+}
+
+// Lua returns the value as a lua literal, negative numbers are wrapped in
+// parentheses so they can be safely placed next to any operator.
+func (c *ConstValue) Lua() string {
+	switch c.Kind {
+	case ConstBool:
+		return strconv.FormatBool(c.Bool)
+	case ConstNumber:
+		return formatLuaNumber(c.Number)
+	case ConstString:
+		return QuoteLua(c.String)
+	default:
+		return "nil"
+	}
+}
+
+// QuoteLua returns s as a lua string literal. Only `\`, `"` and control bytes
+// are escaped, everything else is kept byte for byte, LuaJIT doesn't know the
+// `\u` escapes of Go.
+func QuoteLua(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s) + 2)
+	sb.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			sb.WriteString(`\\`)
+		case '"':
+			sb.WriteString(`\"`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				// always 3 digits, a digit after it can't become part of it
+				fmt.Fprintf(&sb, "\\%03d", c)
+			} else {
+				sb.WriteByte(c)
+			}
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+func formatLuaNumber(n float64) string {
+	switch {
+	case math.IsNaN(n):
+		return "(0/0)"
+	case math.IsInf(n, 1):
+		return "math.huge"
+	case math.IsInf(n, -1):
+		return "(-math.huge)"
+	}
+	var s string
+	if n == math.Trunc(n) && math.Abs(n) < 1e15 {
+		s = strconv.FormatInt(int64(n), 10)
+	} else {
+		s = strconv.FormatFloat(n, 'g', -1, 64)
+	}
+	if n < 0 || (n == 0 && math.Signbit(n)) {
+		if n == 0 {
+			s = "-0"
+		}
+		return "(" + s + ")"
+	}
+	return s
+}
@@ -0,0 +1,36 @@
+package ast
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConstValueLua(t *testing.T) {
+	tests := []struct {
+		value *ConstValue
+		want  string
+	}{
+		{&ConstValue{Kind: ConstNil}, "nil"},
+		{NewConstBool(true), "true"},
+		{NewConstNumber(42), "42"},
+		{NewConstNumber(1.5), "1.5"},
+		{NewConstNumber(-3), "(-3)"},
+		{NewConstNumber(math.Copysign(0, -1)), "(-0)"},
+		{NewConstNumber(math.Inf(1)), "math.huge"},
+		{NewConstNumber(math.NaN()), "(0/0)"},
+		{NewConstString("hello"), `"hello"`},
+		{NewConstString(`say "hi"`), `"say \"hi\""`},
+		{NewConstString(`a\b`), `"a\\b"`},
+		{NewConstString("line\nnext\ttab\r"), `"line\nnext\ttab\r"`},
+		{NewConstString("nul\x00" + "1"), `"nul\0001"`},
+		{NewConstString("bell\a del\x7f"), `"bell\007 del\127"`},
+		{NewConstString("café"), `"café"`},
+		{NewConstString("\ufeffbom"), "\"\ufeffbom\""},
+		{NewConstString("\xff\xfe"), "\"\xff\xfe\""},
+	}
+	for _, tt := range tests {
+		if got := tt.value.Lua(); got != tt.want {
+			t.Errorf("Lua() of %#v = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
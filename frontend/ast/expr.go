@@ -105,6 +105,7 @@ type Expr struct {
 	// lua always returns the last value of a condition, nil or not (s = a and b)
 	// so it will break our code because it will be expecting a bool
 	AsCond bool
+	Const  *ConstValue // set by sema if the expression is a compile-time constant
 	span   *common.Span
 }
 
@@ -8,6 +8,7 @@ import (
 type FunctionSignature struct {
 	Params     []FunctionParam
 	Errorable  bool
+	ErrorType  *Type // nil means the default `string` error
 	ReturnType *Type
 }
 
@@ -16,9 +17,12 @@ type Function struct {
 	Name       *lexer.TokIdent // nil if anonymous
 	Params     []FunctionParam
 	Errorable  bool
+	ErrorType  *Type // nil means the default `string` error
 	ReturnType *Type
 	Body       *Block // nil if abstract
 	Attributes Attributes
+	Cfg        *Cfg
+	Doc        string // the `///` comment before it
 	sem        *SemFunction
 	span       common.Span
 	IsItem     bool
@@ -30,6 +34,7 @@ func NewFunction(name *lexer.TokIdent, sig FunctionSignature, body *Block, attri
 		Name:       name,
 		Params:     sig.Params,
 		Errorable:  sig.Errorable,
+		ErrorType:  sig.ErrorType,
 		ReturnType: sig.ReturnType,
 		Body:       body,
 		Attributes: attributes,
@@ -67,6 +72,15 @@ func (f *Function) GlobalName() string {
 	panic("function is not global, cannot get global name")
 }
 
+func (f *Function) HasDefaultParams() bool {
+	for _, param := range f.Params {
+		if param.Default != nil {
+			return true
+		}
+	}
+	return false
+}
+
 func (f *Function) IsFirstParamSelf() bool {
 	params := f.Params
 	if len(params) < 1 {
@@ -80,13 +94,14 @@ func (f *Function) IsFirstParamSelf() bool {
 }
 
 type FunctionParam struct {
-	Name *lexer.TokIdent // nil if defining function as a type definition
-	Type Type            // nil if vararg
-	span common.Span
+	Name    *lexer.TokIdent // nil if defining function as a type definition
+	Type    Type            // nil if vararg
+	Default *Expr           // nil if no default value
+	span    common.Span
 }
 
-func NewFunctionParam(name *lexer.TokIdent, ty Type, span common.Span) FunctionParam {
-	return FunctionParam{Name: name, Type: ty, span: span}
+func NewFunctionParam(name *lexer.TokIdent, ty Type, def *Expr, span common.Span) FunctionParam {
+	return FunctionParam{Name: name, Type: ty, Default: def, span: span}
 }
 
 func (p FunctionParam) Span() common.Span {
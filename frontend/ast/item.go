@@ -24,6 +24,8 @@ func SetItemPublic(item Item, b bool) {
 		v.Public = b
 	case *Trait:
 		v.Public = b
+	case *TypeAlias:
+		v.Public = b
 	}
 }
 
@@ -45,12 +47,54 @@ func SetItemAttributes(item Item, attrs Attributes) bool {
 	return false
 }
 
+// SetItemCfg sets the `#[cfg(...)]` predicates of an item.
+func SetItemCfg(item Item, cfg *Cfg) {
+	switch v := item.(type) {
+	case *Function:
+		v.Cfg = cfg
+	case *Let:
+		v.Cfg = cfg
+	case *Class:
+		v.Cfg = cfg
+	case *ImplClass:
+		v.Cfg = cfg
+	case *Trait:
+		v.Cfg = cfg
+	case *ImplTraitForClass:
+		v.Cfg = cfg
+	case *TypeAlias:
+		v.Cfg = cfg
+	case *Import:
+		v.Cfg = cfg
+	case *Use:
+		v.Cfg = cfg
+	}
+}
+
+// SetItemDoc sets the `///` comment of the items that can be documented.
+func SetItemDoc(item Item, doc string) {
+	switch v := item.(type) {
+	case *Function:
+		v.Doc = doc
+	case *Let:
+		v.Doc = doc
+	case *Class:
+		v.Doc = doc
+	case *Trait:
+		v.Doc = doc
+	case *TypeAlias:
+		v.Doc = doc
+	}
+}
+
 /* Class */
 
 type ClassField struct {
 	Name   lexer.TokIdent
 	Type   Type
 	Public bool
+	Doc    string
+	Cfg    *Cfg
 }
 
 type ClassInstance struct {
@@ -64,9 +108,13 @@ type Class struct {
 	Public         bool
 	Name           lexer.TokIdent
 	Generics       Generics
-	Super          *Type // the type this class extends, if any
+	Super          *Type    // the type this class extends, if any
+	Newtype        *Type    // the underlying type, if this is a `newtype`
+	Underlying     *SemType // resolved Newtype
 	Fields         []ClassField
 	Attributes     Attributes
+	Cfg            *Cfg
+	Doc            string
 	Scope          any
 	CreatedClasses ClassesStack
 	span           common.Span
@@ -99,6 +147,10 @@ func (s *Class) GetClassStack() ClassesStack {
 	return s.CreatedClasses
 }
 
+func (c *Class) IsNewtype() bool {
+	return c.Newtype != nil
+}
+
 func (c *Class) IsGlobal() bool {
 	return c.Attributes.Has("global")
 }
@@ -119,6 +171,7 @@ type ImplClass struct {
 	Generics      Generics
 	Class         Type
 	Methods       []Function
+	Cfg           *Cfg
 	Scope         any
 	GenericsScope any
 	span          common.Span
@@ -142,18 +195,21 @@ func (is *ImplClass) Span() common.Span {
 type Trait struct {
 	Public      bool
 	Name        lexer.TokIdent
+	Generics    Generics
 	SuperTraits []Path // traits that this trait extends
 	Methods     []Function
 	Scope       any
 	Attributes  Attributes
+	Cfg         *Cfg
+	Doc         string
 	Sem         *SemTrait // semantic information, if available
 	span        common.Span
 
 	Checks []func() // these checks are ran in analyzeImplementations
 }
 
-func NewTrait(name lexer.TokIdent, superTraits []Path, methods []Function, span common.Span) *Trait {
-	return &Trait{Name: name, SuperTraits: superTraits, Methods: methods, span: span}
+func NewTrait(name lexer.TokIdent, generics Generics, superTraits []Path, methods []Function, span common.Span) *Trait {
+	return &Trait{Name: name, Generics: generics, SuperTraits: superTraits, Methods: methods, span: span}
 }
 
 func (t *Trait) isItem() {}
@@ -170,6 +226,7 @@ type ImplTraitForClass struct {
 	Trait         Path
 	Class         Type // the type this trait is implemented for
 	Methods       []Function
+	Cfg           *Cfg
 	ResolvedTrait *SemTrait
 	span          common.Span
 
@@ -186,12 +243,35 @@ func (it *ImplTraitForClass) Span() common.Span {
 	return it.span
 }
 
+/* Type Alias */
+
+type TypeAlias struct {
+	Public   bool
+	Name     lexer.TokIdent
+	Generics Generics
+	Type     Type
+	Cfg      *Cfg
+	Doc      string
+	span     common.Span
+}
+
+func NewTypeAlias(name lexer.TokIdent, generics Generics, ty Type, span common.Span) *TypeAlias {
+	return &TypeAlias{Name: name, Generics: generics, Type: ty, span: span}
+}
+
+func (ta *TypeAlias) isItem() {}
+
+func (ta *TypeAlias) Span() common.Span {
+	return ta.span
+}
+
 /* Import */
 
 type Import struct {
 	Public bool
 	Path   lexer.TokString
 	As     *lexer.TokIdent
+	Cfg    *Cfg
 	span   common.Span
 }
 
@@ -211,6 +291,7 @@ type Use struct {
 	Public bool
 	Path   Path
 	As     *lexer.TokIdent
+	Cfg    *Cfg
 	span   common.Span
 }
 
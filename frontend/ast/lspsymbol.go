@@ -7,6 +7,49 @@ type LSPSymbol interface {
 	Span() common.Span
 }
 
+// DocOf is the `///` comment of the item a symbol, value or type refers to.
+func DocOf(v any) string {
+	switch v := v.(type) {
+	case LSPRef:
+		return DocOf(v.decl)
+	case Symbol:
+		if v.data == nil {
+			return ""
+		}
+		return DocOf(v.Data())
+	case *Symbol:
+		return DocOf(*v)
+	case *Value:
+		if v.data == nil {
+			return ""
+		}
+		return DocOf(v.data)
+	case SemType:
+		if v.Kind() == SemClassKind {
+			return v.Class().Def.Doc
+		}
+	case *SemType:
+		return DocOf(*v)
+	case *SemFunction:
+		return v.Def.Doc
+	case Variable:
+		return v.Def.Doc
+	case SemTrait:
+		return v.Def.Doc
+	case *SemTrait:
+		return v.Def.Doc
+	case SemTypeAlias:
+		return v.Def.Doc
+	case *SemTypeAlias:
+		return v.Def.Doc
+	case SemaClassField:
+		return v.Def.Doc
+	case *SemaClassField:
+		return v.Def.Doc
+	}
+	return ""
+}
+
 type LSPRef struct {
 	decl LSPSymbol
 	span common.Span
@@ -13,8 +13,9 @@ type PostfixOp interface {
 /* DotAccess */
 
 type DotAccess struct {
-	Name lexer.TokIdent
-	span common.Span
+	Name   lexer.TokIdent
+	Method *SemFunction // set if this is a method reference, `obj.method`
+	span   common.Span
 }
 
 func NewDotAccess(name lexer.TokIdent, span common.Span) *DotAccess {
@@ -43,21 +44,50 @@ func (c *Catch) Span() common.Span {
 	return c.span
 }
 
+type NamedArg struct {
+	Name  Ident
+	Value Expr
+}
+
+// CallArgSlot is what ends up in a parameter slot after named arguments and
+// defaults are resolved.
+type CallArgSlot struct {
+	Arg     int   // index into AllArgs(), -1 if not passed
+	Default *Expr // default value to use when Arg is -1, nil means `nil`
+}
+
 type Call struct {
 	Method    *Ident // nil if regular call
 	Args      []Expr
+	NamedArgs []NamedArg
 	IsTryCall bool
 	Catch     *Catch
 	SemaFunc  *SemFunction
+	ErrorConv *SemFunction  // converts the callee error for a try-call, if the error types differ
+	Raises    bool          // calls a `#[may_raise]` function directly, so it has to be protected
+	ArgSlots  []CallArgSlot // nil if arguments map 1:1 to parameters
 	span      common.Span
 }
 
-func NewCall(method *Ident, args []Expr, isTryCall bool, catch *Catch, span common.Span) *Call {
-	return &Call{Method: method, Args: args, IsTryCall: isTryCall, Catch: catch, span: span}
+func NewCall(method *Ident, args []Expr, namedArgs []NamedArg, isTryCall bool, catch *Catch, span common.Span) *Call {
+	return &Call{Method: method, Args: args, NamedArgs: namedArgs, IsTryCall: isTryCall, Catch: catch, span: span}
 }
 
 func (c *Call) isPostfixOp() {}
 
+// AllArgs returns positional arguments followed by named ones, in source order.
+func (c *Call) AllArgs() []Expr {
+	if len(c.NamedArgs) == 0 {
+		return c.Args
+	}
+	args := make([]Expr, 0, len(c.Args)+len(c.NamedArgs))
+	args = append(args, c.Args...)
+	for _, named := range c.NamedArgs {
+		args = append(args, named.Value)
+	}
+	return args
+}
+
 func (c *Call) Span() common.Span {
 	return c.span
 }
@@ -164,7 +164,8 @@ func (t SemType) IsTable() bool   { return t.isNamed("table") }
 func (t SemType) IsVec() bool     { return t.isNamed("vec") }
 func (t SemType) IsMap() bool     { return t.isNamed("map") }
 func (t SemType) IsBool() bool    { return t.isNamed("bool") }
-func (t SemType) IsNumber() bool  { return t.isNamed("number") }
+func (t SemType) IsNumber() bool  { return t.isNamed("number") || t.IsInt() }
+func (t SemType) IsInt() bool     { return t.isNamed("int") }
 func (t SemType) IsString() bool  { return t.isNamed("string") }
 func (t SemType) IsLogical() bool { return t.IsBool() || t.IsNilable() }
 
@@ -256,6 +257,13 @@ func (s SemClass) String() string {
 
 func (s SemClass) LSPString() string {
 	var sb strings.Builder
+	if s.Def.Underlying != nil {
+		sb.WriteString("newtype ")
+		sb.WriteString(s.Def.Name.Raw)
+		sb.WriteString(" = ")
+		sb.WriteString(s.Def.Underlying.String())
+		return sb.String()
+	}
 	sb.WriteString("class ")
 	sb.WriteString(s.Def.Name.Raw)
 	sb.WriteString(s.Generics.String())
@@ -349,6 +357,7 @@ type SemFunction struct {
 	Def    Function
 	Params []SemType
 	Return SemType
+	Error  SemType // type of thrown values, only set if the function is errorable
 
 	Class    *SemClass
 	Trait    *SemTrait // Trait this function is defined in, if any
@@ -380,6 +389,9 @@ func (t SemFunction) String() string {
 
 	if def.Errorable {
 		sb.WriteString(" !")
+		if def.ErrorType != nil {
+			sb.WriteString(t.Error.String())
+		}
 	}
 
 	if !t.Return.IsNil() {
@@ -13,6 +13,7 @@ const (
 	SymType                    // class / alias / type-def
 	SymImport
 	SymTrait
+	SymTypeAlias
 
 	SymClassField
 )
@@ -27,6 +28,7 @@ func (t *SemType) SymbolKind() SymbolKind        { return SymType }
 func (i *SemImport) SymbolKind() SymbolKind      { return SymImport }
 func (f *SemaClassField) SymbolKind() SymbolKind { return SymClassField }
 func (t *SemTrait) SymbolKind() SymbolKind       { return SymTrait }
+func (t *SemTypeAlias) SymbolKind() SymbolKind   { return SymTypeAlias }
 
 type symbolDataBox struct {
 	Data symbolData
@@ -127,6 +129,17 @@ func (s *Symbol) Trait() *SemTrait {
 	return s.Data().(*SemTrait)
 }
 
+func (s *Symbol) IsTypeAlias() bool {
+	return s.Kind() == SymTypeAlias
+}
+
+func (s *Symbol) TypeAlias() *SemTypeAlias {
+	if s.Kind() != SymTypeAlias {
+		panic("not a type alias")
+	}
+	return s.Data().(*SemTypeAlias)
+}
+
 type SemImport struct {
 	Path     string
 	Def      Import
@@ -161,6 +174,12 @@ type SemTrait struct {
 	SuperTraits []*SemTrait // traits that this trait extends
 	Methods     map[string]*SemFunction
 	Scope       any
+
+	// for a generic trait, like `From<E>`, every set of type arguments gets an
+	// instance of its own, Generic is the declared trait and Args the arguments
+	Generic   *SemTrait
+	Args      []SemType
+	Instances []*SemTrait
 }
 
 func NewSemTrait(def *Trait) SemTrait {
@@ -172,7 +191,22 @@ func NewSemTrait(def *Trait) SemTrait {
 }
 
 func (t SemTrait) String() string {
-	return t.Def.Name.Raw
+	if len(t.Args) == 0 {
+		return t.Def.Name.Raw
+	}
+	args := make([]string, len(t.Args))
+	for i, arg := range t.Args {
+		args[i] = arg.String()
+	}
+	return t.Def.Name.Raw + "<" + strings.Join(args, ", ") + ">"
+}
+
+// Origin returns the declared trait of an instance of a generic trait.
+func (t *SemTrait) Origin() *SemTrait {
+	if t.Generic != nil {
+		return t.Generic
+	}
+	return t
 }
 
 func (t SemTrait) LSPString() string {
@@ -192,3 +226,41 @@ func (t SemTrait) LSPString() string {
 func (t SemTrait) Span() common.Span {
 	return t.Def.Name.Span()
 }
+
+// SemTypeAlias is resolved at every use, so generic arguments can be
+// substituted, the alias itself never shows up as a type.
+type SemTypeAlias struct {
+	Def       *TypeAlias
+	Scope     any
+	Resolved  *SemType // set for non generic aliases once checked
+	Resolving bool     // to catch aliases referring to themselves
+}
+
+func NewSemTypeAlias(def *TypeAlias, scope any) SemTypeAlias {
+	return SemTypeAlias{Def: def, Scope: scope}
+}
+
+func (t SemTypeAlias) LSPString() string {
+	var sb strings.Builder
+	sb.WriteString("type ")
+	sb.WriteString(t.Def.Name.Raw)
+	if len(t.Def.Generics.Params) > 0 {
+		sb.WriteString("<")
+		for i, g := range t.Def.Generics.Params {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(g.Name.Raw)
+		}
+		sb.WriteString(">")
+	}
+	if t.Resolved != nil {
+		sb.WriteString(" = ")
+		sb.WriteString(t.Resolved.String())
+	}
+	return sb.String()
+}
+
+func (t SemTypeAlias) Span() common.Span {
+	return t.Def.Name.Span()
+}
@@ -16,12 +16,14 @@ type Let struct {
 	Public bool
 
 	Attributes Attributes
+	Cfg        *Cfg
 	Names      []lexer.TokIdent
 	Types      []*Type
 	Values     []Expr
 
 	IsItem  bool
-	IsConst bool // true if this is a `const` declaration, false if `let`
+	IsConst bool   // true if this is a `const` declaration, false if `let`
+	Doc     string // the `///` comment before it, for items
 	span    common.Span
 }
 
@@ -167,3 +169,20 @@ func (c *StmtContinue) isStmt() {}
 func (c *StmtContinue) Span() common.Span {
 	return c.span
 }
+
+/* Defer */
+
+type StmtDefer struct {
+	Body Block
+	span common.Span
+}
+
+func NewDeferStmt(body Block, span common.Span) *StmtDefer {
+	return &StmtDefer{Body: body, span: span}
+}
+
+func (d *StmtDefer) isStmt() {}
+
+func (d *StmtDefer) Span() common.Span {
+	return d.span
+}
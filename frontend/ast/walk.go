@@ -0,0 +1,113 @@
+package ast
+
+// WalkBlock visits every statement and expression of a block in source order,
+// parents before their children. node is either a Stmt or an *Expr, returning
+// false skips the children of node. Bodies of nested functions are visited,
+// statements turned off by `#[cfg(...)]` aren't.
+func WalkBlock(b *Block, fn func(node any) bool) {
+	for _, stmt := range b.ActiveStmts() {
+		walkStmt(stmt, fn)
+	}
+}
+
+func WalkExpr(e *Expr, fn func(node any) bool) {
+	if !fn(e) {
+		return
+	}
+	switch d := e.data.(type) {
+	case *ExprBinary:
+		WalkExpr(&d.Left, fn)
+		WalkExpr(&d.Right, fn)
+	case *ExprUnary:
+		WalkExpr(&d.Value, fn)
+	case *ExprPostfix:
+		WalkExpr(&d.Left, fn)
+		switch op := d.Op.(type) {
+		case *Call:
+			walkExprs(op.Args, fn)
+			for i := range op.NamedArgs {
+				WalkExpr(&op.NamedArgs[i].Value, fn)
+			}
+			if op.Catch != nil {
+				WalkBlock(&op.Catch.Block, fn)
+			}
+		case *Else:
+			WalkExpr(&op.Value, fn)
+		}
+	case *Block:
+		WalkBlock(d, fn)
+	case *ExprIf:
+		WalkExpr(&d.Main.Cond, fn)
+		WalkBlock(&d.Main.Then, fn)
+		for i := range d.Branches {
+			WalkExpr(&d.Branches[i].Cond, fn)
+			WalkBlock(&d.Branches[i].Then, fn)
+		}
+		if d.Else != nil {
+			WalkBlock(d.Else, fn)
+		}
+	case *ExprWhile:
+		WalkExpr(&d.Cond, fn)
+		WalkBlock(&d.Body, fn)
+	case *ExprLoop:
+		WalkBlock(&d.Body, fn)
+	case *ExprForNum:
+		WalkExpr(&d.Start, fn)
+		WalkExpr(&d.End, fn)
+		if d.Step != nil {
+			WalkExpr(d.Step, fn)
+		}
+		WalkBlock(&d.Body, fn)
+	case *ExprForIn:
+		WalkExpr(&d.InExpr, fn)
+		WalkBlock(&d.Body, fn)
+	case *ExprClassInit:
+		for i := range d.Fields {
+			WalkExpr(&d.Fields[i].Value, fn)
+		}
+	case *ExprTuple:
+		walkExprs(d.Values, fn)
+	case *UnsafeCast:
+		WalkExpr(&d.Expr, fn)
+	case *ExprRunRaw:
+		walkExprs(d.Args, fn)
+	case *ExprVecInit:
+		walkExprs(d.Values, fn)
+	case *ExprMapInit:
+		for i := range d.Entries {
+			WalkExpr(&d.Entries[i].Key, fn)
+			WalkExpr(&d.Entries[i].Value, fn)
+		}
+	case *Function:
+		if d.Body != nil {
+			WalkBlock(d.Body, fn)
+		}
+	}
+}
+
+func walkExprs(exprs []Expr, fn func(node any) bool) {
+	for i := range exprs {
+		WalkExpr(&exprs[i], fn)
+	}
+}
+
+func walkStmt(stmt Stmt, fn func(node any) bool) {
+	if !fn(stmt) {
+		return
+	}
+	switch s := stmt.(type) {
+	case *Let:
+		walkExprs(s.Values, fn)
+	case *StmtReturn:
+		walkExprs(s.Exprs, fn)
+	case *StmtExpr:
+		WalkExpr(&s.Expr, fn)
+	case *StmtAssignment:
+		walkExprs(s.LhsExprs, fn)
+		walkExprs(s.RhsExpr, fn)
+	case *StmtThrow:
+		WalkExpr(&s.Value, fn)
+	case *StmtDefer:
+		WalkBlock(&s.Body, fn)
+	}
+}
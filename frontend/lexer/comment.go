@@ -31,6 +31,30 @@ func (t TokComment) AsString() string {
 	return ""
 }
 
+// TokDocComment is a `///` comment, it documents the item after it.
+type TokDocComment struct {
+	Text string // without the slashes and the space after them
+	span common.Span
+}
+
+func (t TokDocComment) isToken() {}
+
+func (t TokDocComment) Span() common.Span {
+	return t.span
+}
+
+func (t TokDocComment) String() string {
+	return t.Text
+}
+
+func (t TokDocComment) Is(_ string) bool {
+	return false
+}
+
+func (t TokDocComment) AsString() string {
+	return ""
+}
+
 /* Lexing */
 
 func (lx *lexer) comment() *TokComment {
@@ -53,6 +77,16 @@ func (lx *lexer) comment() *TokComment {
 	}
 }
 
+// docComment turns a `///` comment into a doc comment, `////` and longer
+// ones are plain comments.
+func docComment(comment *TokComment) (*TokDocComment, bool) {
+	if !strings.HasPrefix(comment.Text, "/") || strings.HasPrefix(comment.Text, "//") {
+		return nil, false
+	}
+	text := strings.TrimPrefix(comment.Text[1:], " ")
+	return &TokDocComment{Text: strings.TrimRight(text, "\r"), span: comment.span}, true
+}
+
 func (lx *lexer) multilineComment() (Token, *diagnostic) {
 	var sb strings.Builder
 
@@ -34,6 +34,7 @@ const (
 	KwUnreachable
 	KwUnderscore
 	KwConst
+	KwDefer
 	KwAnd // Lua-reserved below
 	KwLocal
 	KwDo
@@ -76,6 +77,7 @@ var keywordTable = map[string]Keyword{
 	"unreachable":    KwUnreachable,
 	"_":              KwUnderscore,
 	"const":          KwConst,
+	"defer":          KwDefer,
 	// Lua reserved
 	"and":      KwAnd,
 	"local":    KwLocal,
@@ -110,6 +112,12 @@ func lookupKeyword(lit string) (Keyword, bool) {
 	return kw, ok
 }
 
+// IsKeyword tells if s is reserved, so it can't be used as a name.
+func IsKeyword(s string) bool {
+	_, ok := keywordTable[s]
+	return ok
+}
+
 type TokKeyword struct {
 	Keyword Keyword
 	span    common.Span
@@ -121,6 +121,9 @@ func (lx *lexer) NextToken() (Token, *diagnostic) {
 			switch *pC {
 			case '/':
 				comment := lx.comment()
+				if doc, ok := docComment(comment); ok {
+					return *doc, nil
+				}
 				return lx.NextToken() // just for now
 				return comment, nil
 			case '*':
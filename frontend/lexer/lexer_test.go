@@ -0,0 +1,75 @@
+package lexer
+
+import (
+	"strings"
+	"testing"
+)
+
+func lexString(t *testing.T, code string) string {
+	t.Helper()
+	toks, diag := Lex("test.gluax", code)
+	if diag != nil {
+		t.Fatalf("Lex(%q) failed: %s", code, diag.Message)
+	}
+	parts := make([]string, 0, len(toks))
+	for _, tok := range toks {
+		switch tok := tok.(type) {
+		case TokEOF:
+			continue
+		case TokDocComment:
+			parts = append(parts, "///"+tok.Text)
+		default:
+			parts = append(parts, tok.String())
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestLexComments(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"let x = 5 // five", "let x = 5"},
+		{"let x = a + b // note\n+ c;", "let x = a + b + c ;"},
+		{"f(x) // call", "f ( x )"},
+		{"v[1] // index", "v [ 1 ]"},
+		{`"s" // string`, "s"},
+		{"true // bool", "true"},
+		{"a /* inline */ + b", "a + b"},
+		{"/// docs\nfunc f()", "///docs func f ( )"},
+		{"//// not docs\nfunc f()", "func f ( )"},
+	}
+	for _, tt := range tests {
+		if got := lexString(t, tt.code); got != tt.want {
+			t.Errorf("Lex(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestLexIntDiv(t *testing.T) {
+	tests := []struct {
+		code string
+		want Punct
+	}{
+		{"a ~/ b", PunctTildeSlash},
+		{"a~/b", PunctTildeSlash},
+		{"~b", PunctTilde},
+		{"a / b", PunctSlash},
+	}
+	for _, tt := range tests {
+		toks, diag := Lex("test.gluax", tt.code)
+		if diag != nil {
+			t.Fatalf("Lex(%q) failed: %s", tt.code, diag.Message)
+		}
+		var puncts []Punct
+		for _, tok := range toks {
+			if p, ok := tok.(TokPunct); ok {
+				puncts = append(puncts, p.Punct)
+			}
+		}
+		if len(puncts) != 1 || puncts[0] != tt.want {
+			t.Errorf("Lex(%q) puncts = %v, want [%v]", tt.code, puncts, tt.want)
+		}
+	}
+}
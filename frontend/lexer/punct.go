@@ -74,6 +74,8 @@ const (
 	PunctPipe
 	// PunctTilde is `~`
 	PunctTilde
+	// PunctTildeSlash is `~/`
+	PunctTildeSlash
 	// PunctAmpersand is `&`
 	PunctAmpersand
 	// PunctExponent is `**`
@@ -118,6 +120,7 @@ var puncts = map[string]Punct{
 	"->":  PunctArrow,
 	"|":   PunctPipe,
 	"~":   PunctTilde,
+	"~/":  PunctTildeSlash,
 	"&":   PunctAmpersand,
 	"**":  PunctExponent,
 	"?":   PunctQuestion,
@@ -291,6 +294,10 @@ func (lx *lexer) punct(c *rune) Token {
 		return newTokPunct(PunctAt, lx.CurrentSpan())
 	case '~':
 		lx.Advance()
+		if IsChr(lx.CurChr, '/') {
+			lx.Advance()
+			return newTokPunct(PunctTildeSlash, lx.CurrentSpan())
+		}
 		return newTokPunct(PunctTilde, lx.CurrentSpan())
 	default:
 		return nil
@@ -62,6 +62,8 @@ func getBinaryOperatorPrecedence(op string) (int, associativity, ast.BinaryOp, b
 		return 10, assocLeft, ast.BinaryOpMul, true
 	case "/":
 		return 10, assocLeft, ast.BinaryOpDiv, true
+	case "~/":
+		return 10, assocLeft, ast.BinaryOpIntDiv, true
 	case "%":
 		return 10, assocLeft, ast.BinaryOpMod, true
 
@@ -7,13 +7,34 @@ func (p *parser) parseBlock() ast.Block {
 
 	p.expect("{")
 
-	var stmts []ast.Stmt
+	var (
+		stmts []ast.Stmt
+		cfgs  []*ast.Cfg
+	)
 	for !p.Token.Is("}") {
-		stmts = append(stmts, p.parseStmt())
+		// `#` is also the length operator, `#[1, 2]` can't be a statement though
+		var attributes []ast.Attribute
+		for p.Token.Is("#") && p.peek().Is("[") {
+			attributes = append(attributes, p.parseAttribute())
+		}
+		stmt := p.parseStmt()
+		if !onlyCfg(attributes) {
+			p.Error(stmt.Span(), "only `#[cfg(...)]` attributes can be put on statements")
+		}
+		cfg := p.parseCfg(attributes)
+		if cfg != nil && cfgs == nil {
+			cfgs = make([]*ast.Cfg, len(stmts))
+		}
+		if cfgs != nil {
+			cfgs = append(cfgs, cfg)
+		}
+		stmts = append(stmts, stmt)
 	}
 
 	p.expect("}")
 
 	span := SpanFrom(spanStart, p.prevSpan())
-	return ast.NewBlock(stmts, span)
+	block := ast.NewBlock(stmts, span)
+	block.Cfgs = cfgs
+	return block
 }
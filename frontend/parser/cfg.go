@@ -0,0 +1,160 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/gluax-lang/gluax/common"
+	"github.com/gluax-lang/gluax/frontend/ast"
+	"github.com/gluax-lang/gluax/frontend/lexer"
+)
+
+// parseCfg parses the predicates of the `#[cfg(...)]` attributes in attrs, it
+// is nil if there are none. The node keeps them, the analysis of each realm
+// is what turns it off.
+//
+//	#[cfg(server)]
+//	#[cfg(client)]
+//	#[cfg(feature = "x")]          -> x is defined, by -D, [defines] or a profile
+//	#[cfg(not(a))], #[cfg(all(a, b))], #[cfg(any(a, b))]
+//
+// Malformed predicates are reported and left out.
+func (p *parser) parseCfg(attrs []ast.Attribute) *ast.Cfg {
+	var cfg *ast.Cfg
+	for _, attr := range attrs {
+		if attr.Key.Raw != "cfg" {
+			continue
+		}
+		if cfg == nil {
+			cfg = &ast.Cfg{}
+		}
+		if !attr.IsInputTokenTree() || len(attr.TokenTree) == 0 {
+			p.Error(attr.Span, "expected `#[cfg(predicate)]`")
+			continue
+		}
+		cp := cfgParser{toks: attr.TokenTree, end: attr.Span}
+		pred, err := cp.predicate()
+		if err == nil && cp.pos < len(cp.toks) {
+			err = cp.errorf(cp.toks[cp.pos].Span(), "unexpected `%s`, `cfg` takes a single predicate", cp.toks[cp.pos])
+		}
+		if err != nil {
+			p.Error(err.span, err.msg)
+			continue
+		}
+		cfg.Predicates = append(cfg.Predicates, pred)
+	}
+	return cfg
+}
+
+type cfgError struct {
+	msg  string
+	span common.Span
+}
+
+// onlyCfg reports if every attribute is a `cfg` one.
+func onlyCfg(attrs []ast.Attribute) bool {
+	for _, attr := range attrs {
+		if attr.Key.Raw != "cfg" {
+			return false
+		}
+	}
+	return true
+}
+
+type cfgParser struct {
+	toks []lexer.Token
+	pos  int
+	end  common.Span // where to point at when the predicate stops early
+}
+
+func (cp *cfgParser) errorf(span common.Span, format string, args ...any) *cfgError {
+	return &cfgError{fmt.Sprintf(format, args...), span}
+}
+
+func (cp *cfgParser) peek() lexer.Token {
+	if cp.pos < len(cp.toks) {
+		return cp.toks[cp.pos]
+	}
+	return nil
+}
+
+func (cp *cfgParser) accept(punct string) bool {
+	if tok := cp.peek(); tok != nil && tok.Is(punct) {
+		cp.pos++
+		return true
+	}
+	return false
+}
+
+func (cp *cfgParser) predicate() (ast.CfgPredicate, *cfgError) {
+	tok := cp.peek()
+	if tok == nil {
+		return ast.CfgPredicate{}, cp.errorf(cp.end, "expected a `cfg` predicate")
+	}
+	var name string
+	switch tok := tok.(type) {
+	case lexer.TokIdent:
+		name = tok.Raw
+	case lexer.TokKeyword:
+		name = tok.String() // `not`
+	default:
+		return ast.CfgPredicate{}, cp.errorf(tok.Span(), "expected a `cfg` predicate, found `%s`", tok)
+	}
+	span := tok.Span()
+	cp.pos++
+	switch name {
+	case "server":
+		return ast.CfgPredicate{Kind: ast.CfgServer, Span: span}, nil
+	case "client":
+		return ast.CfgPredicate{Kind: ast.CfgClient, Span: span}, nil
+	case "feature":
+		if !cp.accept("=") {
+			return ast.CfgPredicate{}, cp.errorf(span, "expected `feature = \"name\"`")
+		}
+		feature, ok := cp.peek().(lexer.TokString)
+		if !ok {
+			return ast.CfgPredicate{}, cp.errorf(span, "expected `feature = \"name\"`")
+		}
+		cp.pos++
+		return ast.CfgPredicate{Kind: ast.CfgFeature, Feature: feature.Raw, Span: span}, nil
+	case "not", "all", "any":
+		args, err := cp.arguments(name, span)
+		if err != nil {
+			return ast.CfgPredicate{}, err
+		}
+		pred := ast.CfgPredicate{Args: args, Span: span}
+		switch name {
+		case "not":
+			if len(args) != 1 {
+				return ast.CfgPredicate{}, cp.errorf(span, "`not` takes exactly one predicate")
+			}
+			pred.Kind = ast.CfgNot
+		case "all":
+			pred.Kind = ast.CfgAll
+		default:
+			pred.Kind = ast.CfgAny
+		}
+		return pred, nil
+	}
+	return ast.CfgPredicate{}, cp.errorf(span, "unknown `cfg` predicate `%s`, expected `server`, `client`, `feature = \"name\"`, `not`, `all` or `any`", name)
+}
+
+func (cp *cfgParser) arguments(fn string, span common.Span) ([]ast.CfgPredicate, *cfgError) {
+	if !cp.accept("(") {
+		return nil, cp.errorf(span, "expected `(` after `%s`", fn)
+	}
+	var args []ast.CfgPredicate
+	for !cp.accept(")") {
+		arg, err := cp.predicate()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if !cp.accept(",") {
+			if !cp.accept(")") {
+				return nil, cp.errorf(cp.end, "expected `)` to close `%s(`", fn)
+			}
+			break
+		}
+	}
+	return args, nil
+}
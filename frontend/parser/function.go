@@ -11,11 +11,18 @@ func (p *parser) parseFunctionSignature(paramFlags Flags) ast.FunctionSignature
 
 	errorable := p.tryConsume("!")
 
+	var errorType *ast.Type
+	if _, ok := p.Token.(lexer.TokIdent); ok && errorable {
+		ty := p.parseType()
+		errorType = &ty
+	}
+
 	returnType := p.parseFunctionReturnType(FlagTypeTuple | FlagTypeVarArg | FlagFuncReturnUnreachable)
 
 	return ast.FunctionSignature{
 		Params:     params,
 		Errorable:  errorable,
+		ErrorType:  errorType,
 		ReturnType: returnType,
 	}
 }
@@ -29,7 +36,7 @@ func (p *parser) parseFunctionParam(flags Flags, isFirst bool) ast.FunctionParam
 			p.expect(")")
 		}
 		var ty ast.Type = ast.NewVararg(varargTy, varargSpan)
-		return ast.NewFunctionParam(nil, ty, varargSpan)
+		return ast.NewFunctionParam(nil, ty, nil, varargSpan)
 	}
 
 	spanStart := p.span()
@@ -41,7 +48,7 @@ func (p *parser) parseFunctionParam(flags Flags, isFirst bool) ast.FunctionParam
 		if flags.Has(FlagFuncParamSelf) && ident.Raw == "self" {
 			if isFirst {
 				SelfPath := ast.NewSimplePath(lexer.NewTokIdent("Self", ident.Span()))
-				return ast.NewFunctionParam(name, &SelfPath, SpanFrom(spanStart, p.prevSpan()))
+				return ast.NewFunctionParam(name, &SelfPath, nil, SpanFrom(spanStart, p.prevSpan()))
 			} else {
 				common.PanicDiag("`self` can only be used as the first parameter in this context", ident.Span())
 			}
@@ -50,8 +57,15 @@ func (p *parser) parseFunctionParam(flags Flags, isFirst bool) ast.FunctionParam
 	}
 
 	ty := p.parseType()
+
+	var def *ast.Expr
+	if name != nil && p.tryConsume("=") {
+		value := p.parseExpr(ExprCtxNormal)
+		def = &value
+	}
+
 	span := SpanFrom(spanStart, p.prevSpan())
-	return ast.NewFunctionParam(name, ty, span)
+	return ast.NewFunctionParam(name, ty, def, span)
 }
 
 func (p *parser) parseFunctionParams(flags Flags) []ast.FunctionParam {
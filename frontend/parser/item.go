@@ -1,15 +1,15 @@
 package parser
 
 import (
+	"strings"
+
 	"github.com/gluax-lang/gluax/common"
 	"github.com/gluax-lang/gluax/frontend/ast"
+	"github.com/gluax-lang/gluax/frontend/lexer"
 )
 
 func (p *parser) parseItem() ast.Item {
-	var attributes []ast.Attribute
-	for p.Token.Is("#") {
-		attributes = append(attributes, p.parseAttribute())
-	}
+	doc, attributes := p.parseAttributesWithDoc()
 	public := p.tryConsume("pub")
 	var item ast.Item
 	switch p.Token.AsString() {
@@ -32,17 +32,46 @@ func (p *parser) parseItem() ast.Item {
 	case "trait":
 		item = p.parseTrait()
 	default:
-		common.PanicDiag("expected item", p.span())
+		// `type` and `newtype` are not keywords, `type` is a lua function name
+		switch p.contextualKeyword() {
+		case "type":
+			item = p.parseTypeAlias()
+		case "newtype":
+			item = p.parseNewtype()
+		default:
+			common.PanicDiag("expected item", p.span())
+		}
 	}
 	ast.SetItemPublic(item, public)
+	ast.SetItemDoc(item, doc)
 	if len(attributes) > 0 {
-		if !ast.SetItemAttributes(item, attributes) {
+		if !ast.SetItemAttributes(item, attributes) && !onlyCfg(attributes) {
 			common.PanicDiag("cannot set attributes on item", item.Span())
 		}
 	}
+	ast.SetItemCfg(item, p.parseCfg(attributes))
 	return item
 }
 
+// parseAttributesWithDoc parses the attributes of an item, and its doc
+// comment, which can be before them, between them or after them.
+func (p *parser) parseAttributesWithDoc() (string, []ast.Attribute) {
+	var (
+		docs       []string
+		attributes []ast.Attribute
+	)
+	for {
+		if doc := p.doc(); doc != "" {
+			docs = append(docs, doc)
+		}
+		if !p.Token.Is("#") {
+			break
+		}
+		attributes = append(attributes, p.parseAttribute())
+	}
+	return strings.Join(docs, "\n"), attributes
+}
+
 func (p *parser) parseFunction() ast.Item {
 	spanStart := p.span()
 	p.advance() // skip `func`
@@ -82,12 +111,12 @@ func (p *parser) parseClass() ast.Item {
 	)
 
 	for !p.Token.Is("}") {
-		var attributes []ast.Attribute
-		for p.Token.Is("#") {
-			attributes = append(attributes, p.parseAttribute())
-		}
+		doc, attributes := p.parseAttributesWithDoc()
 
-		fields = append(fields, p.parseClassField())
+		field := p.parseClassField()
+		field.Doc = doc
+		field.Cfg = p.parseCfg(attributes)
+		fields = append(fields, field)
 
 		// optional trailing comma
 		if !p.tryConsume(",") {
@@ -134,12 +163,11 @@ func (p *parser) parseImpl() ast.Item {
 		var methods []ast.Function
 
 		for !p.Token.Is("}") {
-			var attributes []ast.Attribute
-			for p.Token.Is("#") {
-				attributes = append(attributes, p.parseAttribute())
-			}
+			doc, attributes := p.parseAttributesWithDoc()
 			method := p.parseClassMethod(false)
 			method.Attributes = attributes
+			method.Doc = doc
+			method.Cfg = p.parseCfg(attributes)
 			methods = append(methods, method)
 		}
 
@@ -154,14 +182,13 @@ func (p *parser) parseImpl() ast.Item {
 	var methods []ast.Function
 
 	for !p.Token.Is("}") {
-		var attributes []ast.Attribute
-		for p.Token.Is("#") {
-			attributes = append(attributes, p.parseAttribute())
-		}
+		doc, attributes := p.parseAttributesWithDoc()
 		pub := p.tryConsume("pub")
 		method := p.parseClassMethod(true)
 		method.Public = pub
 		method.Attributes = attributes
+		method.Doc = doc
+		method.Cfg = p.parseCfg(attributes)
 		methods = append(methods, method)
 	}
 
@@ -208,6 +235,7 @@ func (p *parser) parseTrait() ast.Item {
 	p.expect("trait")
 
 	name := p.expectIdentMsg("expected trait name")
+	generics := p.parseGenerics()
 
 	var superTraits []ast.Path
 	if p.tryConsume(":") {
@@ -225,8 +253,12 @@ func (p *parser) parseTrait() ast.Item {
 	var methods []ast.Function
 
 	for !p.Token.Is("}") {
+		doc, attributes := p.parseAttributesWithDoc()
 		method := p.parseClassMethod(true)
 		method.Public = true
+		method.Attributes = attributes
+		method.Doc = doc
+		method.Cfg = p.parseCfg(attributes)
 		methods = append(methods, method)
 	}
 
@@ -234,7 +266,58 @@ func (p *parser) parseTrait() ast.Item {
 
 	span := SpanFrom(spanStart, p.prevSpan())
 
-	return ast.NewTrait(name, superTraits, methods, span)
+	return ast.NewTrait(name, generics, superTraits, methods, span)
+}
+
+// contextualKeyword returns the current identifier if it is followed by another
+// identifier, like in `type Foo`.
+func (p *parser) contextualKeyword() string {
+	ident, ok := p.Token.(lexer.TokIdent)
+	if !ok {
+		return ""
+	}
+	if _, ok := p.peek().(lexer.TokIdent); !ok {
+		return ""
+	}
+	return ident.Raw
+}
+
+func (p *parser) parseTypeAlias() ast.Item {
+	spanStart := p.span()
+	p.advance() // skip `type`
+
+	name := p.expectIdentMsg("expected type alias name")
+	generics := p.parseGenerics()
+	for _, g := range generics.Params {
+		if len(g.Constraints) > 0 {
+			common.PanicDiag("type alias generics cannot have constraints", g.Span)
+		}
+	}
+
+	p.expect("=")
+	ty := p.parseTypeX(FlagTypeTuple)
+	p.expect(";")
+
+	span := SpanFrom(spanStart, p.prevSpan())
+
+	return ast.NewTypeAlias(name, generics, ty, span)
+}
+
+func (p *parser) parseNewtype() ast.Item {
+	spanStart := p.span()
+	p.advance() // skip `newtype`
+
+	name := p.expectIdentMsg("expected newtype name")
+
+	p.expect("=")
+	ty := p.parseType()
+	p.expect(";")
+
+	span := SpanFrom(spanStart, p.prevSpan())
+
+	st := ast.NewClass(name, ast.NewGenerics(nil, common.SpanDefault()), nil, nil, span)
+	st.Newtype = &ty
+	return st
 }
 
 func (p *parser) parseImport() ast.Item {
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/gluax-lang/gluax/common"
 	"github.com/gluax-lang/gluax/frontend"
@@ -30,13 +31,17 @@ type parser struct {
 	Token       lexer.Token
 	Pos         uint32
 	Diags       []diagnostic
+	// Docs are the doc comments before a token, by its position
+	Docs map[uint32]string
 }
 
 func Parse(tkS []lexer.Token) (astRet *ast.Ast, errors []diagnostic, hardError bool) {
+	tkS, docs := splitDocComments(tkS)
 	p := &parser{
 		TokenStream: tkS,
 		Token:       tkS[0],
 		Pos:         0,
+		Docs:        docs,
 	}
 
 	defer func() {
@@ -91,6 +96,8 @@ func Parse(tkS []lexer.Token) (astRet *ast.Ast, errors []diagnostic, hardError b
 				astRet.Classes = append(astRet.Classes, item)
 			case *ast.Trait:
 				astRet.Traits = append(astRet.Traits, item)
+			case *ast.TypeAlias:
+				astRet.TypeAliases = append(astRet.TypeAliases, item)
 			}
 		}
 	}
@@ -100,6 +107,31 @@ func Parse(tkS []lexer.Token) (astRet *ast.Ast, errors []diagnostic, hardError b
 	return
 }
 
+// splitDocComments takes the doc comments out of the token stream, the lines
+// of each run of them are kept for the token they come before.
+func splitDocComments(tkS []lexer.Token) ([]lexer.Token, map[uint32]string) {
+	tokens := make([]lexer.Token, 0, len(tkS))
+	docs := make(map[uint32]string)
+	var lines []string
+	for _, tok := range tkS {
+		if doc, ok := tok.(lexer.TokDocComment); ok {
+			lines = append(lines, doc.Text)
+			continue
+		}
+		if len(lines) > 0 {
+			docs[uint32(len(tokens))] = strings.Join(lines, "\n")
+			lines = nil
+		}
+		tokens = append(tokens, tok)
+	}
+	return tokens, docs
+}
+
+// doc returns the doc comment of the current token.
+func (p *parser) doc() string {
+	return p.Docs[p.Pos]
+}
+
 func (p *parser) Error(span common.Span, msg string) {
 	p.Diags = append(p.Diags, *common.ErrorDiag(msg, span))
 }
@@ -3,6 +3,7 @@ package parser
 import (
 	"github.com/gluax-lang/gluax/common"
 	"github.com/gluax-lang/gluax/frontend/ast"
+	"github.com/gluax-lang/gluax/frontend/lexer"
 )
 
 func (p *parser) parsePostfixExpr(ctx ExprCtx, left ast.Expr) ast.Expr {
@@ -51,8 +52,20 @@ func (p *parser) parseCall(spanStart common.Span, method *ast.Ident) ast.Postfix
 	p.expect("(")
 
 	var args []ast.Expr
+	var namedArgs []ast.NamedArg
 	p.parseCommaSeparatedDelimited(")", func(p *parser) {
-		args = append(args, p.parseExpr(ExprCtxNormal))
+		if _, ok := p.Token.(lexer.TokIdent); ok && p.peek().Is(":") {
+			name := p.expectIdent()
+			p.advance() // consume ':'
+			value := p.parseExpr(ExprCtxNormal)
+			namedArgs = append(namedArgs, ast.NamedArg{Name: name, Value: value})
+			return
+		}
+		arg := p.parseExpr(ExprCtxNormal)
+		if len(namedArgs) > 0 {
+			common.PanicDiag("positional argument cannot follow named arguments", arg.Span())
+		}
+		args = append(args, arg)
 	})
 
 	tryCall := false
@@ -66,7 +79,7 @@ func (p *parser) parseCall(spanStart common.Span, method *ast.Ident) ast.Postfix
 
 	spanStart = SpanFrom(spanStart, p.prevSpan())
 
-	return ast.NewCall(method, args, tryCall, catch, spanStart)
+	return ast.NewCall(method, args, namedArgs, tryCall, catch, spanStart)
 }
 
 func (p *parser) parseElse() ast.PostfixOp {
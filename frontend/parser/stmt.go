@@ -22,6 +22,8 @@ func (p *parser) parseStmt() ast.Stmt {
 		return p.parseBreak()
 	case "continue":
 		return p.parseContinue()
+	case "defer":
+		return p.parseDefer()
 	default:
 		return p.parseAssignmentOrStmtExpr()
 	}
@@ -104,6 +106,26 @@ func (p *parser) parseThrow() ast.Stmt {
 	return ast.NewThrowStmt(expr, span)
 }
 
+func (p *parser) parseDefer() ast.Stmt {
+	spanStart := p.span()
+	p.advance() // skip `defer`
+
+	var body ast.Block
+	if p.Token.Is("{") {
+		body = p.parseBlock()
+	} else {
+		// `defer expr;` is sugar for `defer { expr; }`
+		exprStart := p.span()
+		expr := p.parseExpr(ExprCtxNormal)
+		p.expect(";")
+		exprSpan := SpanFrom(exprStart, p.prevSpan())
+		body = ast.NewBlock([]ast.Stmt{ast.NewStmtExpr(expr, true, exprSpan)}, exprSpan)
+	}
+
+	span := SpanFrom(spanStart, p.prevSpan())
+	return ast.NewDeferStmt(body, span)
+}
+
 func (p *parser) parseBreak() ast.Stmt {
 	spanStart := p.span()
 	p.advance() // skip `break`
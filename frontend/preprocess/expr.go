@@ -0,0 +1,353 @@
+package preprocess
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// expressions of `#if` and `#elif`, C style: identifiers are replaced by the
+// value of their macro, undefined ones are 0 and ones defined without a value
+// are 1. Comparisons and logical operators give 1 or 0.
+
+// macros can refer to other macros, this stops definitions that loop
+const maxMacroDepth = 16
+
+type exprTokKind uint8
+
+const (
+	exprTokEOF exprTokKind = iota
+	exprTokIdent
+	exprTokNumber
+	exprTokString
+	exprTokOp
+)
+
+type exprTok struct {
+	kind       exprTokKind
+	text       string
+	start, end int // byte offsets in the expression
+}
+
+type exprError struct {
+	msg        string
+	start, end int
+}
+
+func (e *exprError) Error() string { return e.msg }
+
+type exprValue struct {
+	num   float64
+	str   string
+	isStr bool
+}
+
+func numValue(n float64) exprValue { return exprValue{num: n} }
+
+func boolValue(b bool) exprValue {
+	if b {
+		return numValue(1)
+	}
+	return numValue(0)
+}
+
+func (v exprValue) truthy() bool {
+	if v.isStr {
+		return v.str != ""
+	}
+	return v.num != 0
+}
+
+func tokenizeExpr(src string) ([]exprTok, *exprError) {
+	var toks []exprTok
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		start := i
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+			continue
+		case c == '/' && strings.HasPrefix(src[i:], "//"):
+			i = len(src)
+			continue
+		case isIdentStart(c):
+			for i < len(src) && isIdentChar(src[i]) {
+				i++
+			}
+			toks = append(toks, exprTok{exprTokIdent, src[start:i], start, i})
+		case c >= '0' && c <= '9':
+			for i < len(src) && (isIdentChar(src[i]) || src[i] == '.') {
+				i++
+			}
+			toks = append(toks, exprTok{exprTokNumber, src[start:i], start, i})
+		case c == '"':
+			i++
+			for i < len(src) && src[i] != '"' {
+				if src[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= len(src) {
+				return nil, &exprError{"unterminated string", start, len(src)}
+			}
+			i++
+			toks = append(toks, exprTok{exprTokString, src[start:i], start, i})
+		default:
+			op := src[i : i+1]
+			for _, two := range []string{"&&", "||", "==", "!=", "<=", ">="} {
+				if strings.HasPrefix(src[i:], two) {
+					op = two
+					break
+				}
+			}
+			if _, ok := exprOps[op]; !ok {
+				return nil, &exprError{fmt.Sprintf("unexpected `%s`", op), start, start + len(op)}
+			}
+			i += len(op)
+			toks = append(toks, exprTok{exprTokOp, op, start, i})
+		}
+	}
+	return append(toks, exprTok{exprTokEOF, "", len(src), len(src)}), nil
+}
+
+var exprOps = map[string]struct{}{
+	"&&": {}, "||": {}, "!": {}, "(": {}, ")": {},
+	"==": {}, "!=": {}, "<": {}, "<=": {}, ">": {}, ">=": {},
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+func isWord(s string) bool {
+	if s == "" || !isIdentStart(s[0]) {
+		return false
+	}
+	for i := range len(s) {
+		if !isIdentChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+type exprParser struct {
+	p     *preprocessor
+	toks  []exprTok
+	pos   int
+	depth int
+	// errors inside macro values are reported at the identifier that used it
+	at *exprTok
+}
+
+// evalExpr evaluates the condition of an `#if` or `#elif`.
+func (p *preprocessor) evalExpr(src string) (bool, *exprError) {
+	v, err := p.evalExprValue(src, 0, nil)
+	if err != nil {
+		return false, err
+	}
+	return v.truthy(), nil
+}
+
+func (p *preprocessor) evalExprValue(src string, depth int, at *exprTok) (exprValue, *exprError) {
+	toks, err := tokenizeExpr(src)
+	if err != nil {
+		return exprValue{}, err
+	}
+	ep := &exprParser{p: p, toks: toks, depth: depth, at: at}
+	if ep.peek().kind == exprTokEOF {
+		return exprValue{}, ep.errorf(ep.peek(), "expected an expression")
+	}
+	v, err := ep.or()
+	if err != nil {
+		return exprValue{}, err
+	}
+	if tok := ep.peek(); tok.kind != exprTokEOF {
+		return exprValue{}, ep.errorf(tok, "unexpected `%s`", tok.text)
+	}
+	return v, nil
+}
+
+func (ep *exprParser) peek() exprTok {
+	return ep.toks[ep.pos]
+}
+
+func (ep *exprParser) next() exprTok {
+	tok := ep.toks[ep.pos]
+	if tok.kind != exprTokEOF {
+		ep.pos++
+	}
+	return tok
+}
+
+func (ep *exprParser) accept(op string) bool {
+	if tok := ep.peek(); tok.kind == exprTokOp && tok.text == op {
+		ep.pos++
+		return true
+	}
+	return false
+}
+
+func (ep *exprParser) errorf(tok exprTok, format string, args ...any) *exprError {
+	if ep.at != nil {
+		tok = *ep.at
+	}
+	return &exprError{fmt.Sprintf(format, args...), tok.start, max(tok.end, tok.start+1)}
+}
+
+func (ep *exprParser) or() (exprValue, *exprError) {
+	left, err := ep.and()
+	for err == nil && ep.accept("||") {
+		var right exprValue
+		right, err = ep.and()
+		left = boolValue(left.truthy() || right.truthy())
+	}
+	return left, err
+}
+
+func (ep *exprParser) and() (exprValue, *exprError) {
+	left, err := ep.comparison()
+	for err == nil && ep.accept("&&") {
+		var right exprValue
+		right, err = ep.comparison()
+		left = boolValue(left.truthy() && right.truthy())
+	}
+	return left, err
+}
+
+func (ep *exprParser) comparison() (exprValue, *exprError) {
+	left, err := ep.unary()
+	if err != nil {
+		return left, err
+	}
+	tok := ep.peek()
+	switch {
+	case tok.kind != exprTokOp:
+		return left, nil
+	case tok.text == "==", tok.text == "!=", tok.text == "<", tok.text == "<=", tok.text == ">", tok.text == ">=":
+	default:
+		return left, nil
+	}
+	ep.next()
+	right, err := ep.unary()
+	if err != nil {
+		return right, err
+	}
+	if left.isStr != right.isStr {
+		return exprValue{}, ep.errorf(tok, "cannot compare a string with a number")
+	}
+	var c int
+	if left.isStr {
+		c = strings.Compare(left.str, right.str)
+	} else if left.num < right.num {
+		c = -1
+	} else if left.num > right.num {
+		c = 1
+	}
+	switch tok.text {
+	case "==":
+		return boolValue(c == 0), nil
+	case "!=":
+		return boolValue(c != 0), nil
+	case "<":
+		return boolValue(c < 0), nil
+	case "<=":
+		return boolValue(c <= 0), nil
+	case ">":
+		return boolValue(c > 0), nil
+	}
+	return boolValue(c >= 0), nil
+}
+
+func (ep *exprParser) unary() (exprValue, *exprError) {
+	if ep.accept("!") {
+		v, err := ep.unary()
+		return boolValue(!v.truthy()), err
+	}
+	return ep.primary()
+}
+
+func (ep *exprParser) primary() (exprValue, *exprError) {
+	tok := ep.next()
+	switch tok.kind {
+	case exprTokNumber:
+		n, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			i, ierr := strconv.ParseInt(tok.text, 0, 64)
+			if ierr != nil {
+				return exprValue{}, ep.errorf(tok, "invalid number `%s`", tok.text)
+			}
+			n = float64(i)
+		}
+		return numValue(n), nil
+	case exprTokString:
+		s, err := strconv.Unquote(tok.text)
+		if err != nil {
+			return exprValue{}, ep.errorf(tok, "invalid string %s", tok.text)
+		}
+		return exprValue{str: s, isStr: true}, nil
+	case exprTokIdent:
+		return ep.ident(tok)
+	case exprTokOp:
+		if tok.text == "(" {
+			v, err := ep.or()
+			if err != nil {
+				return v, err
+			}
+			if !ep.accept(")") {
+				return exprValue{}, ep.errorf(ep.peek(), "expected `)`")
+			}
+			return v, nil
+		}
+		return exprValue{}, ep.errorf(tok, "unexpected `%s`", tok.text)
+	}
+	return exprValue{}, ep.errorf(tok, "expected an expression")
+}
+
+func (ep *exprParser) ident(tok exprTok) (exprValue, *exprError) {
+	switch tok.text {
+	case "true":
+		return numValue(1), nil
+	case "false":
+		return numValue(0), nil
+	case "__LINE__":
+		return numValue(float64(ep.p.currentLineNum)), nil
+	case "defined":
+		parens := ep.accept("(")
+		name := ep.next()
+		if name.kind != exprTokIdent {
+			return exprValue{}, ep.errorf(name, "expected a macro name after `defined`")
+		}
+		if parens && !ep.accept(")") {
+			return exprValue{}, ep.errorf(ep.peek(), "expected `)`")
+		}
+		return boolValue(ep.p.isMacroDefined(name.text)), nil
+	}
+	value, ok := ep.p.macros[tok.text]
+	switch {
+	case !ok:
+		return numValue(0), nil
+	case strings.TrimSpace(value) == "":
+		return numValue(1), nil
+	case isWord(value) && !ep.p.isMacroDefined(value) && value != "true" && value != "false":
+		// `-D MODE=fast` compares as a string, `MODE == "fast"`
+		return exprValue{str: value, isStr: true}, nil
+	case ep.depth >= maxMacroDepth:
+		return exprValue{}, ep.errorf(tok, "macro `%s` expands into itself", tok.text)
+	}
+	at := ep.at
+	if at == nil {
+		at = &tok
+	}
+	v, err := ep.p.evalExprValue(value, ep.depth+1, at)
+	if err != nil && ep.at == nil {
+		err.msg = fmt.Sprintf("in the value of `%s`: %s", tok.text, err.msg)
+	}
+	return v, err
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 	"unicode/utf16"
 
@@ -17,17 +18,21 @@ type Span = common.Span
 type diagnostic = protocol.Diagnostic
 
 var (
-	definePattern = regexp.MustCompile(`^#define\s+(\w+)(?:\s+(.*))?$`)
-	ifdefPattern  = regexp.MustCompile(`^#ifdef\s+(\w+)$`)
-	ifndefPattern = regexp.MustCompile(`^#ifndef\s+(\w+)$`)
-	elsePattern   = regexp.MustCompile(`^#else$`)
-	elifPattern   = regexp.MustCompile(`^#elif\s+(\w+)$`)
-	endifPattern  = regexp.MustCompile(`^#endif$`)
-	undefPattern  = regexp.MustCompile(`^#undef\s+(\w+)$`)
-	macroPattern  = regexp.MustCompile(`\b(\w+)\b`)
-	stringPattern = regexp.MustCompile(`"[^"]*"`)
+	directivePattern = regexp.MustCompile(`^#([A-Za-z_]\w*)(.*)$`)
+	macroNamePattern = regexp.MustCompile(`^[A-Za-z_]\w*$`)
+	definePattern    = regexp.MustCompile(`^\s*([A-Za-z_]\w*)(?:\s+(.*))?$`)
+	macroPattern     = regexp.MustCompile(`\b(\w+)\b`)
+	stringPattern    = regexp.MustCompile(`"[^"]*"`)
 )
 
+// directives are the names of the known directives, any other `#name` line is
+// code, like the length expression `#items`, and is left to the lexer.
+var directives = map[string]struct{}{
+	"define": {}, "undef": {},
+	"ifdef": {}, "ifndef": {}, "if": {}, "elif": {}, "else": {}, "endif": {},
+	"error": {}, "warning": {},
+}
+
 var disallowedMacros = map[string]struct{}{
 	"__LINE__": {},
 }
@@ -37,8 +42,10 @@ func isDisallowedMacro(name string) bool {
 	return disallowed
 }
 
-// Preprocess processes input text with C-style preprocessor directives
-func Preprocess(input string, defaultMacros map[string]string) (string, *diagnostic) {
+// Preprocess processes input text with C-style preprocessor directives. It
+// returns the warnings of `#warning` directives, and an error diagnostic if
+// the input can't be preprocessed.
+func Preprocess(input string, defaultMacros map[string]string) (string, []diagnostic, *diagnostic) {
 	macros := make(map[string]string, len(defaultMacros))
 	maps.Copy(macros, defaultMacros)
 
@@ -48,12 +55,16 @@ func Preprocess(input string, defaultMacros map[string]string) (string, *diagnos
 		outputLines: make([]string, 0),
 	}
 
-	return processor.process(input)
+	output, err := processor.process(input)
+	return output, processor.warnings, err
 }
 
 type condState struct {
 	active      bool
 	hasBeenTrue bool // Track if any branch has been active
+	sawElse     bool
+	line        uint32 // line of the directive that opened it
+	text        string
 }
 
 type preprocessor struct {
@@ -61,6 +72,7 @@ type preprocessor struct {
 	condStack      []condState
 	outputLines    []string
 	currentLineNum uint32
+	warnings       []diagnostic
 }
 
 func (p *preprocessor) process(input string) (string, *diagnostic) {
@@ -78,7 +90,8 @@ func (p *preprocessor) process(input string) (string, *diagnostic) {
 	}
 
 	if len(p.condStack) > 0 {
-		return "", common.ErrorDiag("Unclosed #ifdef block", common.SpanDefault())
+		open := p.condStack[len(p.condStack)-1]
+		return "", p.throwErr("Unclosed #if block, missing #endif", open.line, open.text)
 	}
 
 	return strings.Join(p.outputLines, "\n"), nil
@@ -97,117 +110,222 @@ func (p *preprocessor) processLine(line, trimmed string, lineNum uint32) *diagno
 	return nil
 }
 
+// directive is a `#name args` line, col is where args start in line.
+type directive struct {
+	name string
+	args string
+	col  int
+	line string
+	num  uint32
+}
+
 func (p *preprocessor) processDirective(line, trimmed string, lineNum uint32) *diagnostic {
-	switch {
-	case definePattern.MatchString(trimmed):
-		return p.handleDefine(trimmed, lineNum, line)
-	case undefPattern.MatchString(trimmed):
-		return p.handleUndef(trimmed)
-	case ifdefPattern.MatchString(trimmed):
-		return p.handleIfdef(trimmed, lineNum, line)
-	case ifndefPattern.MatchString(trimmed):
-		return p.handleIfndef(trimmed, lineNum, line)
-	case elifPattern.MatchString(trimmed):
-		return p.handleElif(trimmed, lineNum, line)
-	case elsePattern.MatchString(trimmed):
-		return p.handleElse(lineNum, line)
-	case endifPattern.MatchString(trimmed):
-		return p.handleEndif(lineNum, line)
-	default:
-		// Unknown directive, process as regular line
+	caps := directivePattern.FindStringSubmatch(trimmed)
+	if caps == nil {
+		// attributes, `#[...]`
 		p.processRegularLine(line)
 		return nil
 	}
-}
+	if _, ok := directives[caps[1]]; !ok {
+		p.processRegularLine(line)
+		return nil
+	}
+	d := directive{name: caps[1], args: caps[2], col: len(line) - len(caps[2]), line: line, num: lineNum}
+	if d.args != "" && d.args[0] != ' ' && d.args[0] != '\t' && d.args[0] != '(' && !strings.HasPrefix(d.args, "//") {
+		// `#name.field` and friends are expressions
+		p.processRegularLine(line)
+		return nil
+	}
+	if d.name != "error" && d.name != "warning" && d.name != "define" {
+		d.args = stripComment(d.args)
+	}
 
-func (p *preprocessor) handleDefine(trimmed string, lineNum uint32, line string) *diagnostic {
-	if p.isAllActive() {
-		caps := definePattern.FindStringSubmatch(trimmed)
-		name := caps[1]
-		if p.isMacroDefined(name) {
-			return p.throwErr("Macro '"+name+"' is already defined", lineNum, line)
-		}
-		value := ""
-		if len(caps) > 2 {
-			value = strings.TrimSpace(caps[2])
-		}
-		p.macros[name] = value
+	var err *diagnostic
+	switch d.name {
+	case "define":
+		err = p.handleDefine(d)
+	case "undef":
+		err = p.handleUndef(d)
+	case "ifdef", "ifndef":
+		err = p.handleIfdef(d)
+	case "if":
+		err = p.handleIf(d)
+	case "elif":
+		err = p.handleElif(d)
+	case "else":
+		err = p.handleElse(d)
+	case "endif":
+		err = p.handleEndif(d)
+	case "error", "warning":
+		err = p.handleMessage(d)
+	}
+	if err != nil {
+		return err
 	}
 	p.outputLines = append(p.outputLines, "")
 	return nil
 }
 
-func (p *preprocessor) handleUndef(trimmed string) *diagnostic {
-	if p.isAllActive() {
-		caps := undefPattern.FindStringSubmatch(trimmed)
-		name := caps[1]
-		if isDisallowedMacro(name) {
-			return p.throwErr("Cannot undefine predefined macro '"+name+"'", p.currentLineNum, trimmed)
+// stripComment removes a trailing `//` comment from directive arguments.
+func stripComment(args string) string {
+	inString := false
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == '\\' && inString:
+			i++
+		case args[i] == '"':
+			inString = !inString
+		case !inString && strings.HasPrefix(args[i:], "//"):
+			return args[:i]
 		}
-		delete(p.macros, name)
 	}
-	p.outputLines = append(p.outputLines, "")
-	return nil
+	return args
 }
 
-func (p *preprocessor) handleIfdef(trimmed string, lineNum uint32, line string) *diagnostic {
-	caps := ifdefPattern.FindStringSubmatch(trimmed)
+// macroName parses arguments that must be a single macro name.
+func (p *preprocessor) macroName(d directive) (string, *diagnostic) {
+	name := strings.TrimSpace(d.args)
+	if !macroNamePattern.MatchString(name) {
+		return "", p.errAt(fmt.Sprintf("Expected a macro name after '#%s'", d.name), d, d.col, len(d.line))
+	}
+	return name, nil
+}
+
+func (p *preprocessor) handleDefine(d directive) *diagnostic {
+	if !p.isAllActive() {
+		return nil
+	}
+	caps := definePattern.FindStringSubmatch(d.args)
+	if caps == nil {
+		return p.errAt("Expected a macro name after '#define'", d, d.col, len(d.line))
+	}
 	name := caps[1]
+	if p.isMacroDefined(name) {
+		return p.throwErr("Macro '"+name+"' is already defined", d.num, d.line)
+	}
+	p.macros[name] = strings.TrimSpace(caps[2])
+	return nil
+}
+
+func (p *preprocessor) handleUndef(d directive) *diagnostic {
+	if !p.isAllActive() {
+		return nil
+	}
+	name, err := p.macroName(d)
+	if err != nil {
+		return err
+	}
+	if isDisallowedMacro(name) {
+		return p.throwErr("Cannot undefine predefined macro '"+name+"'", d.num, d.line)
+	}
+	delete(p.macros, name)
+	return nil
+}
+
+func (p *preprocessor) handleIfdef(d directive) *diagnostic {
 	parentActive := p.isAllActive()
-	isActive := parentActive && p.isMacroDefined(name)
-	p.condStack = append(p.condStack, condState{isActive, isActive})
-	p.outputLines = append(p.outputLines, "")
+	isActive := false
+	if parentActive {
+		name, err := p.macroName(d)
+		if err != nil {
+			return err
+		}
+		isActive = p.isMacroDefined(name) == (d.name == "ifdef")
+	}
+	p.condStack = append(p.condStack, condState{active: isActive, hasBeenTrue: isActive, line: d.num, text: d.line})
 	return nil
 }
 
-func (p *preprocessor) handleIfndef(trimmed string, lineNum uint32, line string) *diagnostic {
-	caps := ifndefPattern.FindStringSubmatch(trimmed)
-	name := caps[1]
+func (p *preprocessor) handleIf(d directive) *diagnostic {
 	parentActive := p.isAllActive()
-	isActive := parentActive && !p.isMacroDefined(name)
-	p.condStack = append(p.condStack, condState{isActive, isActive})
-	p.outputLines = append(p.outputLines, "")
+	isActive := false
+	if parentActive {
+		var err *diagnostic
+		if isActive, err = p.condition(d); err != nil {
+			return err
+		}
+	}
+	p.condStack = append(p.condStack, condState{active: isActive, hasBeenTrue: isActive, line: d.num, text: d.line})
 	return nil
 }
 
-func (p *preprocessor) handleElif(trimmed string, lineNum uint32, line string) *diagnostic {
-	if len(p.condStack) == 0 {
-		return p.throwErr("#elif without matching #ifdef", lineNum, line)
+// condition evaluates the expression of an `#if` or `#elif`.
+func (p *preprocessor) condition(d directive) (bool, *diagnostic) {
+	ok, err := p.evalExpr(d.args)
+	if err != nil {
+		return false, p.errAt(err.msg, d, d.col+err.start, d.col+err.end)
 	}
+	return ok, nil
+}
 
-	caps := elifPattern.FindStringSubmatch(trimmed)
-	name := caps[1]
-	parentActive := p.isParentActive()
+func (p *preprocessor) handleElif(d directive) *diagnostic {
+	if len(p.condStack) == 0 {
+		return p.throwErr("#elif without matching #if", d.num, d.line)
+	}
 	top := &p.condStack[len(p.condStack)-1]
+	if top.sawElse {
+		return p.throwErr("#elif after #else", d.num, d.line)
+	}
 
-	// Only activate if parent is active, no previous branch was true, and macro exists
-	top.active = parentActive && !top.hasBeenTrue && p.isMacroDefined(name)
+	// Only evaluated if the parent is active and no previous branch was true
+	top.active = false
+	if p.isParentActive() && !top.hasBeenTrue {
+		active, err := p.condition(d)
+		if err != nil {
+			return err
+		}
+		top.active = active
+	}
 	if top.active {
 		top.hasBeenTrue = true
 	}
-	p.outputLines = append(p.outputLines, "")
 	return nil
 }
 
-func (p *preprocessor) handleElse(lineNum uint32, line string) *diagnostic {
+func (p *preprocessor) handleElse(d directive) *diagnostic {
 	if len(p.condStack) == 0 {
-		return p.throwErr("#else without matching #ifdef", lineNum, line)
+		return p.throwErr("#else without matching #if", d.num, d.line)
+	}
+	if strings.TrimSpace(d.args) != "" {
+		return p.errAt("'#else' takes no arguments", d, d.col, len(d.line))
 	}
-
-	parentActive := p.isParentActive()
 	top := &p.condStack[len(p.condStack)-1]
-	top.active = parentActive && !top.hasBeenTrue
-	p.outputLines = append(p.outputLines, "")
+	if top.sawElse {
+		return p.throwErr("#else after #else", d.num, d.line)
+	}
+	top.sawElse = true
+	top.active = p.isParentActive() && !top.hasBeenTrue
 	return nil
 }
 
-func (p *preprocessor) handleEndif(lineNum uint32, line string) *diagnostic {
+func (p *preprocessor) handleEndif(d directive) *diagnostic {
 	if len(p.condStack) == 0 {
-		return p.throwErr("#endif without matching #ifdef", lineNum, line)
+		return p.throwErr("#endif without matching #if", d.num, d.line)
+	}
+	if strings.TrimSpace(d.args) != "" {
+		return p.errAt("'#endif' takes no arguments", d, d.col, len(d.line))
 	}
-
 	p.condStack = p.condStack[:len(p.condStack)-1]
-	p.outputLines = append(p.outputLines, "")
+	return nil
+}
+
+// handleMessage handles `#error` and `#warning`, an error stops preprocessing.
+func (p *preprocessor) handleMessage(d directive) *diagnostic {
+	if !p.isAllActive() {
+		return nil
+	}
+	msg := strings.TrimSpace(d.args)
+	if unquoted, err := strconv.Unquote(msg); err == nil {
+		msg = unquoted
+	}
+	if msg == "" {
+		msg = "#" + d.name
+	}
+	start := len(d.line) - len(strings.TrimLeft(d.line, " \t"))
+	if d.name == "error" {
+		return p.errAt(msg, d, start, len(d.line))
+	}
+	p.warnings = append(p.warnings, *common.WarningDiag(msg, p.span(d.line, d.num, start, len(d.line))))
 	return nil
 }
 
@@ -288,7 +406,17 @@ func (p *preprocessor) isParentActive() bool {
 }
 
 func (p *preprocessor) throwErr(msg string, lineNum uint32, line string) *diagnostic {
-	utf16Len := uint32(len(utf16.Encode([]rune(line))))
-	span := common.SpanNew(lineNum, lineNum, 0, uint32(len(line)), 0, utf16Len)
-	return common.ErrorDiag(msg, span)
+	return common.ErrorDiag(msg, p.span(line, lineNum, 0, len(line)))
+}
+
+func (p *preprocessor) errAt(msg string, d directive, start, end int) *diagnostic {
+	return common.ErrorDiag(msg, p.span(d.line, d.num, start, end))
+}
+
+// span covers the bytes start to end of a 1-based line.
+func (p *preprocessor) span(line string, lineNum uint32, start, end int) Span {
+	start, end = min(start, len(line)), min(end, len(line))
+	utf16Start := uint32(len(utf16.Encode([]rune(line[:start]))))
+	utf16End := uint32(len(utf16.Encode([]rune(line[:end]))))
+	return common.SpanNew(lineNum-1, lineNum-1, uint32(start), uint32(end), utf16Start, utf16End)
 }
@@ -0,0 +1,107 @@
+package preprocess
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPreprocess(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		macros map[string]string
+		want   string
+	}{
+		{
+			name:  "length expression lines are code",
+			input: "let n =\n#items\n;\n#items\n#s unsafe_cast_as int;",
+			want:  "let n =\n#items\n;\n#items\n#s unsafe_cast_as int;",
+		},
+		{
+			name:  "unknown names are left to the lexer",
+			input: "#region\n#pragma once\n#endfi",
+			want:  "#region\n#pragma once\n#endfi",
+		},
+		{
+			name:  "attributes are code",
+			input: "#[inline]\nfunc f() {}",
+			want:  "#[inline]\nfunc f() {}",
+		},
+		{
+			name:  "directive names as expressions",
+			input: "#if.len\n#define.x",
+			want:  "#if.len\n#define.x",
+		},
+		{
+			name:   "ifdef keeps the active branch",
+			input:  "#ifdef SERVER\na\n#else\nb\n#endif\nc",
+			macros: map[string]string{"SERVER": ""},
+			want:   "\na\n\n\n\nc",
+		},
+		{
+			name:  "ifndef",
+			input: "#ifndef SERVER\na\n#endif",
+			want:  "\na\n",
+		},
+		{
+			name:   "if with defined and elif",
+			input:  "#if defined(CLIENT) && !defined(SERVER)\na\n#elif SERVER\nb\n#endif",
+			macros: map[string]string{"SERVER": "1"},
+			want:   "\n\n\nb\n",
+		},
+		{
+			name:  "define substitutes outside of strings",
+			input: "#define LIMIT 10\nlet x = LIMIT; // \"LIMIT\"\nlet s = \"LIMIT\";",
+			want:  "\nlet x = 10; // \"LIMIT\"\nlet s = \"LIMIT\";",
+		},
+		{
+			name:  "directives in skipped blocks are not run",
+			input: "#ifdef NOPE\n#define X 1\n#error never\n#endif\nX",
+			want:  "\n\n\n\nX",
+		},
+	}
+	for _, tt := range tests {
+		got, _, diag := Preprocess(tt.input, tt.macros)
+		if diag != nil {
+			t.Errorf("%s: unexpected error: %s", tt.name, diag.Message)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPreprocessErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string // part of the message
+	}{
+		{"#if SERVER\na", "Unclosed #if block"},
+		{"#endif", "#endif"},
+		{"#else\n", "#else"},
+		{"#ifdef SERVER\n#else x\n#endif", "'#else' takes no arguments"},
+		{"#define", "Expected a macro name"},
+		{"#error stop here", "stop here"},
+	}
+	for _, tt := range tests {
+		_, _, diag := Preprocess(tt.input, nil)
+		if diag == nil {
+			t.Errorf("Preprocess(%q): expected an error", tt.input)
+			continue
+		}
+		if !strings.Contains(diag.Message, tt.want) {
+			t.Errorf("Preprocess(%q) = %q, want it to contain %q", tt.input, diag.Message, tt.want)
+		}
+	}
+}
+
+func TestPreprocessWarning(t *testing.T) {
+	_, warnings, diag := Preprocess("#warning check this\nx", nil)
+	if diag != nil {
+		t.Fatalf("unexpected error: %s", diag.Message)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0].Message, "check this") {
+		t.Errorf("warnings = %v, want one about %q", warnings, "check this")
+	}
+}
@@ -31,6 +31,9 @@ var CLASS_PREFIX = defineConst("class_")
 var TRAIT_PREFIX = defineConst("trait_")
 var UNREACHABLE_PREFIX = defineConst("unreachable_")
 var LOCAL_PREFIX = defineConst("local_")
+var RAISED_FUNC = defineConst("raised")
+var ERRMAP_PREFIX = defineConst("errmap_")
+var SPILL_TBL = defineConst("spill")
 
 var PUBLIC_TBL = defineConst("public")
 
@@ -33,6 +33,7 @@ func (pa *ProjectAnalysis) StripWorkspace(path string) string {
 
 type Analysis struct {
 	Src                   string // source file name
+	Code                  string // source after preprocessing, spans point into it
 	Workspace             string // workspace root
 	Scope                 *Scope // root scope
 	Diags                 []Diagnostic
@@ -43,6 +44,7 @@ type Analysis struct {
 	State                 *State // current state of the analysis
 	currentClassSetupSpan *Span  // used to track the span of the current class setup
 	Exprs                 []*ast.Expr
+	Deps                  []string // resolved paths of the files it imports, in order
 }
 
 func (a *Analysis) SetClassSetupSpan(span Span) bool {
@@ -80,6 +82,10 @@ func (a *Analysis) Warning(span Span, msg string) {
 	a.Diags = append(a.Diags, *common.WarningDiag(msg, span))
 }
 
+func (a *Analysis) Warningf(span Span, format string, args ...any) {
+	a.Warning(span, fmt.Sprintf(format, args...))
+}
+
 func (a *Analysis) panic(span Span, msg string) {
 	a.Error(span, msg)
 	panic("")
@@ -146,6 +152,11 @@ func (a *Analysis) getBuiltinType(name string) Type {
 	return *ty
 }
 
+// isBuiltinTypes reports whether this is the file declaring the builtin types.
+func (a *Analysis) isBuiltinTypes() bool {
+	return a.Project.isBuiltinTypesFile(a.Src)
+}
+
 func (a *Analysis) nilType() Type {
 	return a.getBuiltinType("nil")
 }
@@ -158,6 +169,10 @@ func (a *Analysis) numberType() Type {
 	return a.getBuiltinType("number")
 }
 
+func (a *Analysis) intType() Type {
+	return a.getBuiltinType("int")
+}
+
 func (a *Analysis) stringType() Type {
 	return a.getBuiltinType("string")
 }
@@ -216,6 +231,9 @@ func (a *Analysis) MatchesPanic(ty, other Type, span Span) {
 func (a *Analysis) populateDeclarations() {
 	astD := a.Ast
 	for _, traitDef := range astD.Traits {
+		if !traitDef.Cfg.Active() {
+			continue
+		}
 		traitDef.Scope = a.Scope
 		trait := ast.NewSemTrait(traitDef)
 		trait.Scope = a.Scope.Child(false)
@@ -227,6 +245,9 @@ func (a *Analysis) populateDeclarations() {
 	}
 
 	for _, stDef := range astD.Classes {
+		if !stDef.Cfg.Active() {
+			continue
+		}
 		stDef.Scope = a.Scope
 		st := a.setupClass(stDef, nil, false)
 		stSem := ast.NewSemType(st, stDef.Name.Span())
@@ -234,13 +255,31 @@ func (a *Analysis) populateDeclarations() {
 		a.AddDecl(stSem)
 	}
 
+	for _, aliasDef := range astD.TypeAliases {
+		if !aliasDef.Cfg.Active() {
+			continue
+		}
+		alias := ast.NewSemTypeAlias(aliasDef, a.Scope)
+		sym := ast.NewSymbol(aliasDef.Name.Raw, &alias, aliasDef.Name.Span(), aliasDef.Public)
+		if err := a.Scope.AddSymbol(aliasDef.Name.Raw, sym); err != nil {
+			a.Error(aliasDef.Name.Span(), err.Error())
+		}
+		a.AddDecl(alias)
+	}
+
 	for _, funcDef := range astD.Funcs {
+		if !funcDef.Cfg.Active() {
+			continue
+		}
 		fun := &SemFunction{}
 		val := ast.NewValue(fun)
 		a.AddValueVisibility(a.Scope, funcDef.Name.Raw, val, funcDef.Name.Span(), funcDef.Public)
 	}
 
 	for _, letDef := range astD.Lets {
+		if !letDef.Cfg.Active() {
+			continue
+		}
 		for i, ident := range letDef.Names {
 			if ident.Raw == "_" {
 				a.panic(ident.Span(), "cannot use `_` in top level let binding")
@@ -254,12 +293,21 @@ func (a *Analysis) populateDeclarations() {
 
 func (a *Analysis) resolveUses() {
 	for _, use := range a.Ast.Uses {
+		if !use.Cfg.Active() {
+			continue
+		}
 		a.handleUse(a.Scope, use)
 	}
 }
 
 func (a *Analysis) resolveImplementations() {
+	a.resolveTypeAliases()
+	a.resolveNewtypes()
+
 	for _, funcDef := range a.Ast.Funcs {
+		if !funcDef.Cfg.Active() {
+			continue
+		}
 		funcSem := a.handleFunctionSignature(a.Scope, funcDef)
 		funcDef.SetSem(funcSem)
 		sym := a.Scope.GetSymbol(funcDef.Name.Raw)
@@ -271,6 +319,9 @@ func (a *Analysis) resolveImplementations() {
 	}
 
 	for _, letDef := range a.Ast.Lets {
+		if !letDef.Cfg.Active() {
+			continue
+		}
 		for i, ident := range letDef.Names {
 			ty := a.resolveType(a.Scope, *letDef.Types[i])
 			variable := ast.NewVariable(letDef, i, ty)
@@ -280,13 +331,12 @@ func (a *Analysis) resolveImplementations() {
 	}
 
 	for _, traitDef := range a.Ast.Traits {
+		if !traitDef.Cfg.Active() {
+			continue
+		}
 		for _, super := range traitDef.SuperTraits {
-			superDef := a.resolvePathSymbol(a.Scope, &super)
-			if !superDef.IsTrait() {
-				a.panic(super.Span(), "expected trait")
-			}
 			trait := traitDef.Sem
-			superTrait := superDef.Trait()
+			superTrait := a.resolveBoundTrait(a.Scope, &super)
 			if causesTraitCycle(trait, superTrait) {
 				a.panicf(super.Span(), "cyclic supertrait: trait `%s` is (directly or indirectly) a supertrait of itself", trait.Def.Name.Raw)
 			}
@@ -295,6 +345,9 @@ func (a *Analysis) resolveImplementations() {
 	}
 
 	for _, stDef := range a.Ast.Classes {
+		if !stDef.Cfg.Active() {
+			continue
+		}
 		st := a.GetClass(stDef, nil)
 		a.buildGenericsTable(st.Scope.(*Scope), st, nil)
 
@@ -311,6 +364,9 @@ func (a *Analysis) resolveImplementations() {
 	}
 
 	for _, stDef := range a.Ast.Classes {
+		if !stDef.Cfg.Active() {
+			continue
+		}
 		superDef := stDef.Super
 		if superDef == nil {
 			continue
@@ -324,7 +380,8 @@ func (a *Analysis) resolveImplementations() {
 		}
 
 		superClass := superT.Class()
-		if superClass.Def.Attributes.Has("sealed") {
+		// the builtin types declare `int: number` themselves
+		if superClass.Def.Attributes.Has("sealed") && !a.isBuiltinTypes() {
 			a.panicf((*superDef).Span(), "cannot inherit from sealed class `%s`", superClass.Def.Name.Raw)
 		}
 
@@ -332,6 +389,9 @@ func (a *Analysis) resolveImplementations() {
 	}
 
 	for _, stDef := range a.Ast.Classes {
+		if !stDef.Cfg.Active() {
+			continue
+		}
 		st := a.GetClass(stDef, nil)
 		stScope := st.Scope.(*Scope)
 		SelfSt := stScope.GetType("Self").Class()
@@ -344,17 +404,25 @@ func (a *Analysis) resolveImplementations() {
 	}
 
 	for _, traitDef := range a.Ast.Traits {
+		if !traitDef.Cfg.Active() {
+			continue
+		}
 		trait := traitDef.Sem
-		scope := trait.Scope.(*Scope)
+		scope := a.setupTypeGenerics(trait.Scope.(*Scope), traitDef.Generics, nil)
 		SelfScope := scope.Child(false)
 		SelfGeneric := ast.NewSemGenericType(lexer.NewTokIdent("Self", traitDef.Name.Span()), append([]*ast.SemTrait{trait}, trait.SuperTraits...), true)
 		SelfScope.ForceAddType("Self", SelfGeneric)
 		for _, method := range traitDef.Methods {
+			if !method.Cfg.Active() {
+				continue
+			}
 			name := method.Name.Raw
 			if _, exists := trait.Methods[name]; exists {
 				a.panicf(method.Name.Span(), "duplicate method `%s` in trait `%s`", name, traitDef.Name.Raw)
 			}
-			if !method.IsFirstParamSelf() {
+			// generic traits can't be bounds, so their static methods are only
+			// ever called on a class
+			if !method.IsFirstParamSelf() && len(traitDef.Generics.Params) == 0 {
 				a.panicf(method.Name.Span(), "trait `%s` method `%s` must have a `self` parameter as the first parameter", traitDef.Name.Raw, method.Name.Raw)
 			}
 			funcTy := a.handleFunctionSignature(SelfScope, &method)
@@ -371,15 +439,14 @@ func (a *Analysis) resolveImplementations() {
 	}
 
 	for _, implTrait := range a.Ast.ImplTraits {
-		traitPath := a.resolvePathSymbol(a.Scope, &implTrait.Trait)
-		if !traitPath.IsTrait() {
-			a.panic(implTrait.Trait.Span(), "expected trait")
+		if !implTrait.Cfg.Active() {
+			continue
 		}
-		trait := traitPath.Trait()
-		implTrait.ResolvedTrait = trait
-
 		genericsScope := a.setupTypeGenerics(a.Scope, implTrait.Generics, nil)
 
+		trait := a.resolvePathTrait(genericsScope, &implTrait.Trait)
+		implTrait.ResolvedTrait = trait
+
 		stTy := a.resolveType(genericsScope, implTrait.Class)
 		if !stTy.IsClass() {
 			a.panic(implTrait.Class.Span(), "expected class")
@@ -396,7 +463,7 @@ func (a *Analysis) resolveImplementations() {
 				trait.Def.Name.Raw, st.Def.Name.Raw)
 		}
 
-		if trait.Def.Attributes.Has("requires_metatable") && st.Def.Attributes.Has("no_metatable") {
+		if trait.Def.Attributes.Has("requires_metatable") && (st.Def.Attributes.Has("no_metatable") || st.Def.IsNewtype()) {
 			a.panicf(implTrait.Span(), "class `%s` cannot implement trait `%s` because it has no metatable", st.Def.Name.Raw, trait.Def.Name.Raw)
 		}
 
@@ -410,6 +477,9 @@ func (a *Analysis) resolveImplementations() {
 
 		implMethods := make(map[string]*ast.SemFunction, len(implTrait.Methods))
 		for _, method := range implTrait.Methods {
+			if !method.Cfg.Active() {
+				continue
+			}
 			if _, exists := implMethods[method.Name.Raw]; exists {
 				a.panicf(method.Name.Span(), "duplicate method `%s` in trait implementation", method.Name.Raw)
 			}
@@ -443,8 +513,11 @@ func (a *Analysis) resolveImplementations() {
 					a.panicf(implTrait.Span(), "class `%s` does not implement trait `%s` method `%s`", st.Def.Name.Raw, trait.Def.Name.Raw, name)
 				}
 			}
-			if !stMethod.IsFirstParamSelf() {
-				a.panicf(implTrait.Span(), "class `%s` method `%s` must have a `self` parameter as the first parameter", st.Def.Name.Raw, name)
+			if stMethod.IsFirstParamSelf() != method.IsFirstParamSelf() {
+				if method.IsFirstParamSelf() {
+					a.panicf(implTrait.Span(), "class `%s` method `%s` must have a `self` parameter as the first parameter", st.Def.Name.Raw, name)
+				}
+				a.panicf(implTrait.Span(), "class `%s` method `%s` must not have a `self` parameter", st.Def.Name.Raw, name)
 			}
 
 			methodCopy := a.HandleClassMethod(st, method, false)
@@ -462,6 +535,9 @@ func (a *Analysis) resolveImplementations() {
 	}
 
 	for _, impl := range a.Ast.ImplClasses {
+		if !impl.Cfg.Active() {
+			continue
+		}
 		impl.Scope = a.Scope
 		genericsScope := a.setupTypeGenerics(a.Scope, impl.Generics, nil)
 		stTy := a.resolveType(genericsScope, impl.Class)
@@ -479,6 +555,9 @@ func (a *Analysis) resolveImplementations() {
 			a.panicf(impl.Span(), "class `%s` cannot implement methods", st.Def.Name.Raw)
 		}
 		for _, method := range impl.Methods {
+			if !method.Cfg.Active() {
+				continue
+			}
 			funcTy := a.handleFunctionSignature(genericsScope, &method)
 			funcTy.Scope = a.Scope
 			funcTy.Generics = impl.Generics
@@ -511,6 +590,9 @@ func (a *Analysis) resolveImplementations() {
 					if s.Def.Errorable != o.Def.Errorable {
 						return false
 					}
+					if s.Def.Errorable && !a.matchTypes(s.Error, o.Error) {
+						return false
+					}
 					if len(s.Params) != len(o.Params) {
 						return false
 					}
@@ -540,16 +622,28 @@ func (a *Analysis) resolveImplementations() {
 
 func (a *Analysis) analyzeImplementations() {
 	for _, implTrait := range a.Ast.ImplTraits {
+		if !implTrait.Cfg.Active() {
+			continue
+		}
 		for _, check := range implTrait.Checks {
 			check()
 		}
 	}
 
 	for _, let := range a.Ast.Lets {
+		if !let.Cfg.Active() {
+			continue
+		}
+		a.checkLetRootAttributes(let)
+		restore := a.withRefOwner(let)
 		a.handleLet(a.Scope, let)
+		restore()
 	}
 
 	for _, f := range a.Ast.Funcs {
+		if !f.Cfg.Active() {
+			continue
+		}
 		if f.Body == nil {
 			if !f.IsGlobal() {
 				a.Error(f.Span(), "function must have a body")
@@ -559,10 +653,16 @@ func (a *Analysis) analyzeImplementations() {
 				a.Error(f.Span(), "function cannot have a body")
 			}
 		}
+		a.checkFuncRootAttributes(f)
+		restore := a.withRefOwner(FuncRef(f.Span().ID))
 		a.handleFunction(a.Scope, f)
+		restore()
 	}
 
 	for _, impl := range a.Ast.ImplClasses {
+		if !impl.Cfg.Active() {
+			continue
+		}
 		for _, check := range impl.Checks {
 			check()
 		}
@@ -571,6 +671,12 @@ func (a *Analysis) analyzeImplementations() {
 			continue
 		}
 		for _, method := range impl.Methods {
+			if !method.Cfg.Active() {
+				continue
+			}
+			if method.Attributes.Has("export", "hook") {
+				a.Error(method.Span(), "`#[export]` and `#[hook]` are only allowed on items, not methods")
+			}
 			if method.Body == nil {
 				if !method.IsGlobal() && !impl.ClassSema.IsGlobal() {
 					a.Error(method.Span(), "must have a body")
@@ -590,6 +696,9 @@ func (a *Analysis) analyzeImplementations() {
 	}
 
 	for _, traitDef := range a.Ast.Traits {
+		if !traitDef.Cfg.Active() {
+			continue
+		}
 		for _, check := range traitDef.Checks {
 			check()
 		}
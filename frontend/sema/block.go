@@ -9,8 +9,12 @@ func (a *Analysis) handleBlock(scope *Scope, block *ast.Block) FlowStatus {
 	blockTy, blockFlow := a.nilType(), FlowNormal
 	lastStmtSpan := block.Span()
 
-	stmtCount := len(block.Stmts)
-	for i, raw := range block.Stmts {
+	for _, cfg := range block.Cfgs {
+		a.resolveCfg(cfg)
+	}
+	stmts := block.ActiveStmts()
+	stmtCount := len(stmts)
+	for i, raw := range stmts {
 		stmtTy, stmtFlow := a.handleStmt(child, raw)
 
 		if exprStmt, ok := raw.(*ast.StmtExpr); ok {
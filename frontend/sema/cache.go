@@ -0,0 +1,94 @@
+package sema
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/gluax-lang/gluax/common"
+	"github.com/gluax-lang/gluax/frontend/ast"
+	"github.com/gluax-lang/gluax/frontend/lexer"
+	"github.com/gluax-lang/gluax/frontend/parser"
+	"github.com/gluax-lang/gluax/frontend/preprocess"
+)
+
+// ParseCache keeps the preprocessed, lexed and parsed form of files between
+// builds, keyed by the hash of everything that goes into them. The analysis
+// annotates ASTs in place, so every build gets its own clone of the cached one.
+// It is not safe for concurrent use.
+type ParseCache struct {
+	entries map[string]*cachedFile
+	// entries not used since the last Prune get dropped by the next one
+	used map[string]struct{}
+}
+
+type cachedFile struct {
+	code     string
+	warnings []Diagnostic
+	ast      *ast.Ast // never analysed, see ParseCache
+	diags    []Diagnostic
+	diag     *Diagnostic // preprocessing or lexing error
+	err      error
+}
+
+func NewParseCache() *ParseCache {
+	return &ParseCache{
+		entries: make(map[string]*cachedFile),
+		used:    make(map[string]struct{}),
+	}
+}
+
+// Prune drops the files no build used since the last call.
+func (c *ParseCache) Prune() {
+	maps.DeleteFunc(c.entries, func(key string, _ *cachedFile) bool {
+		_, ok := c.used[key]
+		return !ok
+	})
+	clear(c.used)
+}
+
+func (c *ParseCache) Len() int {
+	return len(c.entries)
+}
+
+// loadFile preprocesses, lexes and parses a file, reusing the result of an
+// earlier build with the same input when there is a cache.
+func (pa *ProjectAnalysis) loadFile(path, code string, macros map[string]string) *cachedFile {
+	c := pa.Options.Cache
+	var key string
+	if c != nil {
+		var sb strings.Builder
+		sb.WriteString(path)
+		for _, name := range slices.Sorted(maps.Keys(macros)) {
+			sb.WriteString("\x00" + name + "=" + macros[name])
+		}
+		sb.WriteString("\x00\x00" + code)
+		key = common.SHA256Hex(sb.String())
+		if file, ok := c.entries[key]; ok {
+			c.used[key] = struct{}{}
+			return file
+		}
+	}
+
+	file := &cachedFile{}
+	preprocessed, warnings, diag := preprocess.Preprocess(code, macros)
+	file.code, file.warnings = preprocessed, warnings
+	if diag != nil {
+		file.diag, file.err = diag, fmt.Errorf("preprocessing failed")
+	} else if toks, diag := lexer.Lex(path, preprocessed); diag != nil {
+		file.diag, file.err = diag, fmt.Errorf("lexing failed")
+	} else {
+		var hardErr bool
+		file.ast, file.diags, hardErr = parser.Parse(toks)
+		if hardErr {
+			file.ast, file.err = nil, fmt.Errorf("parsing failed")
+		}
+	}
+
+	if c != nil {
+		c.entries[key] = file
+		c.used[key] = struct{}{}
+	}
+	return file
+}
@@ -0,0 +1,58 @@
+package sema
+
+import (
+	"strings"
+
+	"github.com/gluax-lang/gluax/frontend/ast"
+)
+
+// resolveCfg turns the node off if its `#[cfg(...)]` leaves it out of the state
+// being analysed.
+func (a *Analysis) resolveCfg(cfg *ast.Cfg) {
+	cfg.Resolve(strings.ToLower(a.State.Label), a.Project.macros)
+}
+
+// resolveItemCfgs resolves the cfg of every item, field and method of the file,
+// before anything looks at them. Statements are resolved by handleBlock.
+func (a *Analysis) resolveItemCfgs() {
+	root := a.Ast
+	for _, imp := range root.Imports {
+		a.resolveCfg(imp.Cfg)
+	}
+	for _, use := range root.Uses {
+		a.resolveCfg(use.Cfg)
+	}
+	for _, fun := range root.Funcs {
+		a.resolveCfg(fun.Cfg)
+	}
+	for _, let := range root.Lets {
+		a.resolveCfg(let.Cfg)
+	}
+	for _, alias := range root.TypeAliases {
+		a.resolveCfg(alias.Cfg)
+	}
+	for _, class := range root.Classes {
+		a.resolveCfg(class.Cfg)
+		for i := range class.Fields {
+			a.resolveCfg(class.Fields[i].Cfg)
+		}
+	}
+	for _, impl := range root.ImplClasses {
+		a.resolveCfg(impl.Cfg)
+		a.resolveMethodCfgs(impl.Methods)
+	}
+	for _, impl := range root.ImplTraits {
+		a.resolveCfg(impl.Cfg)
+		a.resolveMethodCfgs(impl.Methods)
+	}
+	for _, trait := range root.Traits {
+		a.resolveCfg(trait.Cfg)
+		a.resolveMethodCfgs(trait.Methods)
+	}
+}
+
+func (a *Analysis) resolveMethodCfgs(methods []ast.Function) {
+	for i := range methods {
+		a.resolveCfg(methods[i].Cfg)
+	}
+}
@@ -20,7 +20,7 @@ func (a *Analysis) setupTypeGenerics(scope *Scope, generics ast.Generics, concre
 		if concrete == nil {
 			var traits []*ast.SemTrait
 			for _, constraint := range g.Constraints {
-				trait := a.resolvePathTrait(scope, &constraint)
+				trait := a.resolveBoundTrait(scope, &constraint)
 				traits = append(traits, trait)
 			}
 			binding = ast.NewSemGenericType(g.Name, traits, true)
@@ -78,7 +78,7 @@ func (a *Analysis) buildGenericsTable(scope *Scope, st *SemClass, concrete []Typ
 			for i := range g.Constraints {
 				constraint := &g.Constraints[i]
 				if constraint.ResolvedSymbol == nil {
-					a.resolvePathTrait(scope, constraint)
+					a.resolveBoundTrait(scope, constraint)
 				} else {
 					break // already resolved
 				}
@@ -138,6 +138,9 @@ func (a *Analysis) collectClassFields(st *SemClass) {
 		}
 	}
 	for _, field := range st.Def.Fields {
+		if !field.Cfg.Active() {
+			continue
+		}
 		if _, ok := st.Fields[field.Name.Raw]; ok {
 			a.Error(field.Name.Span(), "duplicate field name")
 		}
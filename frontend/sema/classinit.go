@@ -16,6 +16,9 @@ func (a *Analysis) handleClassInit(scope *Scope, si *ast.ExprClassInit) Type {
 		a.panic(si.Name.Span(), fmt.Sprintf("expected class type for `%s`, found `%s`", si.Name.String(), baseTy.String()))
 	}
 	baseClass := baseTy.Class()
+	if baseClass.Def.IsNewtype() {
+		a.panicf(si.Name.Span(), "cannot construct newtype `%s`, use `unsafe_cast_as` to convert to it", baseClass.Def.Name.Raw)
+	}
 	expected := len(baseClass.Generics.Params)
 
 	// 2) If no generics provided but class has generics => infer
@@ -59,7 +62,7 @@ func (a *Analysis) handleClassInit(scope *Scope, si *ast.ExprClassInit) Type {
 			a.Errorf(f.Name.Span(), "field `%s` of class `%s` is private", f.Name.Raw, baseClass.Def.Name.Raw)
 		}
 		a.handleExpr(scope, &f.Value)
-		exprTy := f.Value.Type()
+		exprTy := a.asIntConst(field.Ty, &f.Value)
 		a.Matches(field.Ty, exprTy, f.Value.Span())
 	}
 
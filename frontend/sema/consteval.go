@@ -0,0 +1,191 @@
+package sema
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/gluax-lang/gluax/frontend/ast"
+)
+
+// evalConst evaluates an already analyzed expression at compile time, returns
+// nil if it's not a constant.
+func (a *Analysis) evalConst(expr *ast.Expr) *ast.ConstValue {
+	switch expr.Kind() {
+	case ast.ExprKindNil:
+		return &ast.ConstValue{Kind: ast.ConstNil}
+	case ast.ExprKindBool:
+		return ast.NewConstBool(expr.Bool())
+	case ast.ExprKindNumber:
+		return parseConstNumber(expr.Number().Raw)
+	case ast.ExprKindString:
+		return ast.NewConstString(expr.String().Raw)
+	case ast.ExprKindPath:
+		return constOfPath(expr.Path())
+	case ast.ExprKindUnary:
+		return a.evalConstUnary(expr.Unary())
+	case ast.ExprKindBinary:
+		return a.evalConstBinary(expr.Binary())
+	default:
+		return nil
+	}
+}
+
+func parseConstNumber(raw string) *ast.ConstValue {
+	if i, err := strconv.ParseInt(raw, 0, 64); err == nil {
+		return ast.NewConstNumber(float64(i))
+	}
+	if f, err := strconv.ParseFloat(raw, 64); err == nil {
+		return ast.NewConstNumber(f)
+	}
+	return nil
+}
+
+func constOfPath(path *ast.Path) *ast.ConstValue {
+	sym := path.ResolvedSymbol
+	if sym == nil || !sym.IsValue() {
+		return nil
+	}
+	val := sym.Value()
+	if val.Kind() != ast.ValVariable {
+		return nil
+	}
+	v := val.Variable()
+	if v.Def == nil || !v.Def.IsConst || len(v.Def.Names) != len(v.Def.Values) {
+		return nil
+	}
+	return v.Def.Values[v.N].Const
+}
+
+func (a *Analysis) evalConstUnary(unE *ast.ExprUnary) *ast.ConstValue {
+	value := unE.Value.Const
+	if value == nil {
+		return nil
+	}
+	switch unE.Op {
+	case ast.UnaryOpNot:
+		if value.Kind == ast.ConstBool {
+			return ast.NewConstBool(!value.Bool)
+		}
+	case ast.UnaryOpNegate:
+		if value.Kind == ast.ConstNumber {
+			return ast.NewConstNumber(-value.Number)
+		}
+	case ast.UnaryOpBitwiseNot:
+		if x, ok := toBit(value); ok {
+			return ast.NewConstNumber(float64(^x))
+		}
+	case ast.UnaryOpLength:
+		if value.Kind == ast.ConstString {
+			return ast.NewConstNumber(float64(len(value.String)))
+		}
+	}
+	return nil
+}
+
+func (a *Analysis) evalConstBinary(binE *ast.ExprBinary) *ast.ConstValue {
+	lhs, rhs := binE.Left.Const, binE.Right.Const
+
+	if rhs != nil && rhs.Kind == ast.ConstNumber && rhs.Number == 0 {
+		switch binE.Op {
+		case ast.BinaryOpDiv, ast.BinaryOpIntDiv:
+			a.Error(binE.Span(), "division by zero")
+			return nil
+		case ast.BinaryOpMod:
+			a.Error(binE.Span(), "modulo by zero")
+			return nil
+		}
+	}
+
+	if lhs == nil || rhs == nil {
+		return nil
+	}
+
+	switch binE.Op {
+	case ast.BinaryOpEqual:
+		return ast.NewConstBool(lhs.Equals(rhs))
+	case ast.BinaryOpNotEqual:
+		return ast.NewConstBool(!lhs.Equals(rhs))
+	case ast.BinaryOpLogicalAnd, ast.BinaryOpLogicalOr:
+		if lhs.Kind != ast.ConstBool || rhs.Kind != ast.ConstBool {
+			return nil
+		}
+		if binE.Op == ast.BinaryOpLogicalAnd {
+			return ast.NewConstBool(lhs.Bool && rhs.Bool)
+		}
+		return ast.NewConstBool(lhs.Bool || rhs.Bool)
+	case ast.BinaryOpConcat:
+		if lhs.Kind != ast.ConstString || rhs.Kind != ast.ConstString {
+			return nil
+		}
+		return ast.NewConstString(lhs.String + rhs.String)
+	case ast.BinaryOpBitwiseOr, ast.BinaryOpBitwiseAnd, ast.BinaryOpBitwiseXor,
+		ast.BinaryOpBitwiseLeftShift, ast.BinaryOpBitwiseRightShift:
+		x, ok1 := toBit(lhs)
+		y, ok2 := toBit(rhs)
+		if !ok1 || !ok2 {
+			return nil
+		}
+		return ast.NewConstNumber(float64(evalBitOp(binE.Op, x, y)))
+	}
+
+	if lhs.Kind != ast.ConstNumber || rhs.Kind != ast.ConstNumber {
+		return nil
+	}
+	x, y := lhs.Number, rhs.Number
+
+	switch binE.Op {
+	case ast.BinaryOpLess:
+		return ast.NewConstBool(x < y)
+	case ast.BinaryOpGreater:
+		return ast.NewConstBool(x > y)
+	case ast.BinaryOpLessEqual:
+		return ast.NewConstBool(x <= y)
+	case ast.BinaryOpGreaterEqual:
+		return ast.NewConstBool(x >= y)
+	case ast.BinaryOpAdd:
+		return ast.NewConstNumber(x + y)
+	case ast.BinaryOpSub:
+		return ast.NewConstNumber(x - y)
+	case ast.BinaryOpMul:
+		return ast.NewConstNumber(x * y)
+	case ast.BinaryOpDiv:
+		return ast.NewConstNumber(x / y)
+	case ast.BinaryOpIntDiv:
+		return ast.NewConstNumber(math.Floor(x / y))
+	case ast.BinaryOpMod:
+		// lua's modulo takes the sign of the divisor
+		return ast.NewConstNumber(x - math.Floor(x/y)*y)
+	case ast.BinaryOpExponent:
+		return ast.NewConstNumber(math.Pow(x, y))
+	}
+	return nil
+}
+
+// toBit converts a number the same way the `bit` library does, only integers
+// are handled so the result never depends on rounding.
+func toBit(c *ast.ConstValue) (int32, bool) {
+	if c.Kind != ast.ConstNumber {
+		return 0, false
+	}
+	n := c.Number
+	if n != math.Trunc(n) || math.Abs(n) >= 1<<53 {
+		return 0, false
+	}
+	return int32(uint32(int64(n))), true
+}
+
+func evalBitOp(op ast.BinaryOp, x, y int32) int32 {
+	switch op {
+	case ast.BinaryOpBitwiseOr:
+		return x | y
+	case ast.BinaryOpBitwiseAnd:
+		return x & y
+	case ast.BinaryOpBitwiseXor:
+		return x ^ y
+	case ast.BinaryOpBitwiseLeftShift:
+		return int32(uint32(x) << (uint32(y) & 31))
+	case ast.BinaryOpBitwiseRightShift:
+		return int32(uint32(x) >> (uint32(y) & 31))
+	}
+	panic("unreachable: not a bitwise operator")
+}
@@ -0,0 +1,38 @@
+package sema
+
+import (
+	"github.com/gluax-lang/gluax/frontend/ast"
+)
+
+func (a *Analysis) resolveErrorType(scope *Scope, it *ast.Function) Type {
+	if it.ErrorType == nil {
+		return a.stringType()
+	}
+	ty := a.resolveType(scope, *it.ErrorType)
+	if !ty.IsClass() || ty.IsNilable() || ty.IsNil() || ty.IsAny() {
+		a.Errorf((*it.ErrorType).Span(), "error type must be a class, got: %s", ty.String())
+		return a.stringType()
+	}
+	return ty
+}
+
+// errorConversion finds how an error of type `from` is propagated out of a
+// function throwing `to`. Matching types are passed through as is, otherwise
+// `to` needs to implement the builtin `From<from>` trait.
+func (a *Analysis) errorConversion(to, from Type, span Span) *SemFunction {
+	if a.matchTypes(to, from) {
+		return nil
+	}
+	if fromTrait := a.State.RootScope.GetTrait("From"); fromTrait != nil && to.IsClass() {
+		for _, trait := range fromTrait.Instances {
+			if !a.matchTypes(trait.Args[0], from) {
+				continue
+			}
+			if method := a.FindClassMethodForTraitOnly(to.Class(), trait, "from"); method != nil {
+				return method
+			}
+		}
+	}
+	a.Errorf(span, "cannot propagate error `%s` out of a function throwing `%s`, implement `From<%s>` for `%s`", from.String(), to.String(), from.String(), to.String())
+	return nil
+}
@@ -1,6 +1,7 @@
 package sema
 
 import (
+	"slices"
 	"strconv"
 
 	"github.com/gluax-lang/gluax/frontend"
@@ -24,7 +25,7 @@ func (a *Analysis) handleExprWithFlow(scope *Scope, expr *ast.Expr) ExprResult {
 	case ast.ExprKindBool:
 		retTy = a.boolType()
 	case ast.ExprKindNumber:
-		retTy = a.numberType()
+		retTy = a.handleNumberLiteral(expr)
 	case ast.ExprKindString:
 		retTy = a.stringType()
 	case ast.ExprKindVararg:
@@ -102,6 +103,8 @@ func (a *Analysis) handleExprWithFlow(scope *Scope, expr *ast.Expr) ExprResult {
 		panic("unreachable: unknown expression kind " + expr.Kind().String())
 	}
 	expr.SetType(retTy)
+	expr.Const = a.evalConst(expr)
+	a.recordRefs(expr)
 	a.Exprs = append(a.Exprs, expr)
 	return res
 }
@@ -187,9 +190,15 @@ func (a *Analysis) handleBinaryExpr(scope *Scope, binE *ast.ExprBinary) Type {
 		}
 		return a.boolType()
 	case ast.BinaryOpBitwiseOr, ast.BinaryOpBitwiseXor, ast.BinaryOpBitwiseAnd,
-		ast.BinaryOpBitwiseLeftShift, ast.BinaryOpBitwiseRightShift,
-		ast.BinaryOpAdd, ast.BinaryOpSub,
-		ast.BinaryOpMul, ast.BinaryOpDiv,
+		ast.BinaryOpBitwiseLeftShift, ast.BinaryOpBitwiseRightShift:
+		a.checkBitOperand(&binE.Left)
+		a.checkBitOperand(&binE.Right)
+		if binE.Op == ast.BinaryOpBitwiseLeftShift || binE.Op == ast.BinaryOpBitwiseRightShift {
+			a.checkShiftAmount(&binE.Right)
+		}
+		return a.intType()
+	case ast.BinaryOpAdd, ast.BinaryOpSub,
+		ast.BinaryOpMul, ast.BinaryOpDiv, ast.BinaryOpIntDiv,
 		ast.BinaryOpMod, ast.BinaryOpExponent:
 		if !lty.IsNumber() {
 			a.Errorf(binE.Left.Span(), "attempted to perform arithmetic on non-number value, got: %s", lty.String())
@@ -197,6 +206,15 @@ func (a *Analysis) handleBinaryExpr(scope *Scope, binE *ast.ExprBinary) Type {
 		if !rty.IsNumber() {
 			a.Errorf(binE.Right.Span(), "attempted to perform arithmetic on non-number value, got: %s", rty.String())
 		}
+		switch binE.Op {
+		case ast.BinaryOpIntDiv:
+			return a.intType()
+		case ast.BinaryOpAdd, ast.BinaryOpSub, ast.BinaryOpMul, ast.BinaryOpMod:
+			// literals stay numbers unless the other side is an int
+			if (lty.IsInt() && isIntExpr(&binE.Right)) || (rty.IsInt() && isIntExpr(&binE.Left)) {
+				return a.intType()
+			}
+		}
 		return a.numberType()
 	case ast.BinaryOpConcat:
 		if !lty.IsString() {
@@ -224,17 +242,21 @@ func (a *Analysis) handleUnaryExpr(scope *Scope, unE *ast.ExprUnary) Type {
 		if !ty.IsNumber() {
 			a.panic(unE.Span(), "unary negate operator requires a number value")
 		}
+		if ty.IsInt() {
+			return a.intType()
+		}
 		return a.numberType()
 	case ast.UnaryOpBitwiseNot:
 		if !ty.IsNumber() {
 			a.panic(unE.Span(), "unary bitwise not operator requires an integer value")
 		}
-		return a.numberType()
+		a.checkBitOperand(&unE.Value)
+		return a.intType()
 	case ast.UnaryOpLength:
 		if !ty.IsString() {
 			a.panic(unE.Span(), "unary length operator requires a string value")
 		}
-		return a.numberType()
+		return a.intType()
 	default:
 		panic("unreachable: unknown unary operator")
 	}
@@ -391,7 +413,12 @@ func (a *Analysis) handleForNumExpr(scope *Scope, forE *ast.ExprForNum) {
 	child := scope.Child(true)
 	child.InLoop = true
 
-	idxVariable := ast.NewSingleVariable(forE.Var, a.numberType())
+	// the index only stays integral if it starts and steps by integers
+	idxTy := a.numberType()
+	if isIntExpr(&forE.Start) && (forE.Step == nil || isIntExpr(forE.Step)) {
+		idxTy = a.intType()
+	}
+	idxVariable := ast.NewSingleVariable(forE.Var, idxTy)
 	a.AddValue(child, forE.Var.Raw, ast.NewValue(idxVariable), forE.Var.Span())
 
 	if forE.Label != nil {
@@ -492,6 +519,9 @@ func (a *Analysis) handleForInExpr(scope *Scope, forIn *ast.ExprForIn) {
 
 func (a *Analysis) handlePostfixExpr(scope *Scope, e *ast.ExprPostfix) Type {
 	expr := &e.Left
+	if call, ok := e.Op.(*ast.Call); ok && call.Method == nil {
+		a.markCallee(expr)
+	}
 	a.handleExpr(scope, expr)
 	exprTy := e.Left.Type()
 
@@ -501,11 +531,12 @@ func (a *Analysis) handlePostfixExpr(scope *Scope, e *ast.ExprPostfix) Type {
 	case *ast.Call:
 		if op.Method == nil {
 			ty = a.handleCall(scope, op, exprTy, expr.Span())
+			op.Raises = isRaisingFuncPath(e.Left)
 		} else {
 			ty = a.handleMethodCall(scope, op, expr)
 		}
 	case *ast.DotAccess:
-		ty = a.handleDotAccess(op, expr)
+		ty = a.handleDotAccess(scope, op, expr)
 	case *ast.Else:
 		ty = a.handleElse(scope, op, expr)
 	case *ast.UnwrapNilable:
@@ -533,6 +564,12 @@ func (a *Analysis) handleCall(scope *Scope, call *ast.Call, toCallTy Type, span
 		if !scope.IsFuncErrorable() {
 			a.panic(call.Span(), "cannot call try-call outside non erroable function")
 		}
+
+		if scope.InDefer {
+			a.Error(call.Span(), "cannot try-call inside a `defer` block, use `catch` instead")
+		}
+
+		call.ErrorConv = a.errorConversion(scope.Func.Error, funcTy.Error, call.Span())
 	}
 
 	var fixedParams []Type
@@ -550,6 +587,7 @@ func (a *Analysis) handleCall(scope *Scope, call *ast.Call, toCallTy Type, span
 	var (
 		processedArgs  []Type
 		processedSpans []Span
+		multiValueArg  *ast.Expr
 	)
 
 	appendArg := func(t Type, s Span) {
@@ -571,34 +609,80 @@ func (a *Analysis) handleCall(scope *Scope, call *ast.Call, toCallTy Type, span
 			if !hasVararg {
 				a.panic(rawArg.Span(), "function does not accept vararg arguments")
 			}
+			multiValueArg = rawArg
 			a.Matches(varargParam, argType, rawArg.Span())
 		case ast.SemTupleKind:
 			if !isLastArg {
 				a.panic(rawArg.Span(), "tuple value is only permitted as the last argument in a call")
 			}
+			multiValueArg = rawArg
 			for _, elemType := range argType.Tuple().Elems {
 				appendArg(elemType, rawArg.Span())
 			}
 		default:
+			if idx := len(processedArgs); idx < len(fixedParams) {
+				argType = a.asIntConst(fixedParams[idx], rawArg)
+			} else if hasVararg {
+				argType = a.asIntConst(varargParam, rawArg)
+			}
 			appendArg(argType, rawArg.Span())
 		}
 	}
 
 	requiredCount := len(fixedParams)
 	actualCount := len(processedArgs)
-
-	lastRequired := requiredCount
-	for i := requiredCount - 1; i >= 0; i-- {
-		p := fixedParams[i]
-		if !p.IsNilable() && !p.IsNil() {
-			break
+	paramDefs := funcTy.Def.Params[:requiredCount]
+
+	// named arguments, keyed by parameter index
+	namedArgs := make(map[int]int, len(call.NamedArgs))
+	for i := range call.NamedArgs {
+		named := &call.NamedArgs[i]
+		a.handleExpr(scope, &named.Value)
+		idx := slices.IndexFunc(paramDefs, func(p ast.FunctionParam) bool {
+			return p.Name != nil && p.Name.Raw == named.Name.Raw
+		})
+		if idx == -1 {
+			a.panicf(named.Name.Span(), "no parameter named `%s`", named.Name.Raw)
+		}
+		if idx < actualCount {
+			a.panicf(named.Name.Span(), "parameter `%s` is already given as a positional argument", named.Name.Raw)
+		}
+		if _, exists := namedArgs[idx]; exists {
+			a.panicf(named.Name.Span(), "parameter `%s` is given more than once", named.Name.Raw)
+		}
+		switch named.Value.Type().Kind() {
+		case ast.SemVarargKind, ast.SemTupleKind:
+			a.panic(named.Value.Span(), "named argument cannot be a tuple or vararg value")
+		}
+		namedArgs[idx] = i
+		a.Matches(funcTy.Params[idx], a.asIntConst(funcTy.Params[idx], &named.Value), named.Value.Span())
+	}
+
+	if len(namedArgs) == 0 {
+		lastRequired := requiredCount
+		for i := requiredCount - 1; i >= 0; i-- {
+			p := fixedParams[i]
+			if paramDefs[i].Default == nil && !p.IsNilable() && !p.IsNil() {
+				break
+			}
+			lastRequired = i
 		}
-		lastRequired = i
-	}
-	minRequired := lastRequired // Only up to here are required
+		minRequired := lastRequired // Only up to here are required
 
-	if actualCount < minRequired {
-		a.panicf(call.Span(), "expected at least %d argument(s), found %d", minRequired, actualCount)
+		if actualCount < minRequired {
+			a.panicf(call.Span(), "expected at least %d argument(s), found %d", minRequired, actualCount)
+		}
+	} else {
+		if actualCount > requiredCount {
+			a.panicf(call.Span(), "expected at most %d positional argument(s), found %d", requiredCount, actualCount)
+		}
+		for i := actualCount; i < requiredCount; i++ {
+			p := fixedParams[i]
+			if _, ok := namedArgs[i]; ok || paramDefs[i].Default != nil || p.IsNilable() || p.IsNil() {
+				continue
+			}
+			a.panicf(call.Span(), "missing argument for parameter `%s`", paramDefs[i].String())
+		}
 	}
 	if !hasVararg && actualCount > requiredCount {
 		a.panicf(call.Span(), "expected at most %d argument(s), found %d", requiredCount, actualCount)
@@ -614,6 +698,32 @@ func (a *Analysis) handleCall(scope *Scope, call *ast.Call, toCallTy Type, span
 		}
 	}
 
+	// parameters past the last one that gets a value can be left out entirely
+	lastSlot := -1
+	for i := actualCount; i < requiredCount; i++ {
+		if _, ok := namedArgs[i]; ok || paramDefs[i].Default != nil {
+			lastSlot = i
+		}
+	}
+	call.ArgSlots = nil
+	if lastSlot != -1 {
+		if multiValueArg != nil {
+			a.panic(multiValueArg.Span(), "tuple or vararg value cannot be used with default or named arguments")
+		}
+		slots := make([]ast.CallArgSlot, lastSlot+1)
+		for i := range slots {
+			switch namedIdx, ok := namedArgs[i]; {
+			case i < actualCount:
+				slots[i] = ast.CallArgSlot{Arg: i}
+			case ok:
+				slots[i] = ast.CallArgSlot{Arg: len(call.Args) + namedIdx}
+			default:
+				slots[i] = ast.CallArgSlot{Arg: -1, Default: paramDefs[i].Default}
+			}
+		}
+		call.ArgSlots = slots
+	}
+
 	if call.SemaFunc == nil {
 		call.SemaFunc = funcTy
 	}
@@ -625,7 +735,7 @@ func (a *Analysis) handleCall(scope *Scope, call *ast.Call, toCallTy Type, span
 	if call.Catch != nil {
 		catch := call.Catch
 		catchScope := scope.Child(true)
-		errVariable := ast.NewSingleVariable(catch.Name, a.stringType())
+		errVariable := ast.NewSingleVariable(catch.Name, funcTy.Error)
 		a.AddValue(catchScope, catch.Name.Raw, ast.NewValue(errVariable), catch.Name.Span())
 
 		a.handleBlock(catchScope, &catch.Block)
@@ -641,8 +751,20 @@ func (a *Analysis) handleCall(scope *Scope, call *ast.Call, toCallTy Type, span
 	return funcTy.Return
 }
 
-func (a *Analysis) handleDotAccess(expr *ast.DotAccess, toIndex *ast.Expr) Type {
+func (a *Analysis) handleDotAccess(scope *Scope, expr *ast.DotAccess, toIndex *ast.Expr) Type {
 	toIndexTy := toIndex.Type()
+	expr.Method = nil
+
+	isField := false
+	if toIndexTy.IsClass() {
+		_, isField = toIndexTy.Class().Fields[expr.Name.Raw]
+	}
+	if !isField {
+		if ty := a.handleMethodRef(scope, expr, toIndexTy); ty != nil {
+			return *ty
+		}
+	}
+
 	if !toIndexTy.IsClass() {
 		a.Errorf(expr.Span(), "cannot index into non-class type `%s`", toIndexTy.String())
 		return a.nilType()
@@ -668,6 +790,48 @@ func (a *Analysis) handleDotAccess(expr *ast.DotAccess, toIndex *ast.Expr) Type
 	return a.nilType()
 }
 
+// handleMethodRef resolves `obj.method` to a function value with `self` bound,
+// returns nil if there is no such method.
+func (a *Analysis) handleMethodRef(scope *Scope, expr *ast.DotAccess, toIndexTy Type) *Type {
+	name := expr.Name.Raw
+	if name == frontend.PARSING_ERROR_PREFIX {
+		return nil
+	}
+
+	methods := a.FindMethodsOnType(scope, toIndexTy, name)
+	if len(methods) == 0 {
+		return nil
+	}
+	if len(methods) > 1 {
+		a.panicf(expr.Name.Span(), "ambiguous method `%s` in `%s`", name, toIndexTy.String())
+	}
+
+	method := methods[0]
+	if !method.IsFirstParamSelf() {
+		return nil
+	}
+
+	if !a.CanAccessClassMethod(method) {
+		a.Errorf(expr.Name.Span(), "method `%s` of class `%s` is private", method.Def.Name.Raw, method.Class.Def.Name.Raw)
+	}
+
+	expr.Method = method
+	a.State.BoundMethods[FuncRefOf(method)] = struct{}{}
+
+	a.AddRef(method, expr.Name.Span())
+
+	// the bound value is a plain function without `self`, it has no body of
+	// its own so it never gets inlined or generated
+	bound := *method
+	bound.Params = method.Params[1:]
+	bound.Def.Params = method.Def.Params[1:]
+	bound.Def.Body = nil
+	bound.Def.Attributes = nil
+
+	ty := ast.NewSemType(&bound, expr.Span())
+	return &ty
+}
+
 func (a *Analysis) handleMethodCall(scope *Scope, call *ast.Call, toCall *ast.Expr) Type {
 	name := call.Method.Raw
 	if name == frontend.PARSING_ERROR_PREFIX {
@@ -699,6 +863,7 @@ func (a *Analysis) handleMethodCall(scope *Scope, call *ast.Call, toCall *ast.Ex
 	}
 
 	call.SemaFunc = method
+	call.Raises = method.Def.Attributes.Has("may_raise")
 
 	a.AddRef(method, call.Method.Span())
 
@@ -807,7 +972,7 @@ func (a *Analysis) handleVecInit(scope *Scope, vecInit *ast.ExprVecInit) Type {
 		if !ty.IsValid() {
 			ty = val.Type()
 		} else {
-			a.Matches(ty, val.Type(), val.Span())
+			a.Matches(ty, a.asIntConst(ty, val), val.Span())
 		}
 	}
 	if !ty.IsValid() {
@@ -834,13 +999,13 @@ func (a *Analysis) handleMapInit(scope *Scope, mapInit *ast.ExprMapInit) Type {
 		if !keyTy.IsValid() {
 			keyTy = field.Key.Type()
 		} else {
-			a.Matches(keyTy, field.Key.Type(), field.Key.Span())
+			a.Matches(keyTy, a.asIntConst(keyTy, &field.Key), field.Key.Span())
 		}
 		a.handleExpr(scope, &field.Value)
 		if !valueTy.IsValid() {
 			valueTy = field.Value.Type()
 		} else {
-			a.Matches(valueTy, field.Value.Type(), field.Value.Span())
+			a.Matches(valueTy, a.asIntConst(valueTy, &field.Value), field.Value.Span())
 		}
 	}
 	if !keyTy.IsValid() {
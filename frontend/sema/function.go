@@ -17,6 +17,7 @@ func (a *Analysis) handleFunctionImpl(scope *Scope, it *ast.Function, withBody b
 
 	// parameters
 	var params []Type
+	seenDefault := false
 	for _, param := range it.Params {
 		ty := a.resolveType(child, param.Type)
 		if param.Name != nil {
@@ -25,6 +26,14 @@ func (a *Analysis) handleFunctionImpl(scope *Scope, it *ast.Function, withBody b
 				a.AddValue(child, param.Name.Raw, ast.NewValue(paramValue), param.Name.Span())
 			}
 		}
+		if withBody {
+			if param.Default != nil {
+				seenDefault = true
+				a.handleParamDefault(scope, param, ty)
+			} else if seenDefault && !ast.IsVararg(param.Type) && !ty.IsNilable() {
+				a.Errorf(param.Span(), "parameter `%s` without a default value cannot follow parameters with default values", param.Name.Raw)
+			}
+		}
 		params = append(params, ty)
 	}
 
@@ -38,6 +47,9 @@ func (a *Analysis) handleFunctionImpl(scope *Scope, it *ast.Function, withBody b
 		Params: params,
 		Return: returnType,
 	}
+	if it.Errorable {
+		funcType.Error = a.resolveErrorType(child, it)
+	}
 	child.Func = funcType
 
 	if returnType.IsTuple() {
@@ -51,7 +63,7 @@ func (a *Analysis) handleFunctionImpl(scope *Scope, it *ast.Function, withBody b
 	if it.Body != nil && !it.IsGlobal() {
 		if withBody {
 			_ = a.handleBlock(child, it.Body)
-			a.Matches(returnType, it.Body.Type(), it.Body.Span())
+			a.Matches(returnType, a.blockAsIntConst(returnType, it.Body), it.Body.Span())
 		}
 	}
 
@@ -59,5 +71,58 @@ func (a *Analysis) handleFunctionImpl(scope *Scope, it *ast.Function, withBody b
 		a.panic(it.Span(), "cannot have vararg return type in erroable function")
 	}
 
+	a.checkInlineAttribute(it)
+	if attr := it.Attributes.Get("unchecked"); attr != nil && !attr.IsInputNone() {
+		a.Error(attr.Span, "`#[unchecked]` takes no arguments")
+	}
+
+	if it.Attributes.Has("may_raise") {
+		if it.Body != nil {
+			a.Error(it.Span(), "`#[may_raise]` is only allowed on functions without a body")
+		} else if !it.Errorable || !funcType.Error.IsString() {
+			a.Error(it.Span(), "`#[may_raise]` functions must be errorable with a `string` error, add `!` before the return type")
+		}
+	}
+	if it.Attributes.Has("engine_callbacks") && it.Body != nil {
+		a.Error(it.Span(), "`#[engine_callbacks]` is only allowed on functions without a body")
+	}
+
 	return funcType
 }
+
+// handleParamDefault checks a parameter default value, defaults are filled in
+// at the call site so they have to be literals.
+func (a *Analysis) handleParamDefault(scope *Scope, param ast.FunctionParam, ty Type) {
+	def := param.Default
+	if !isLiteralExpr(*def) {
+		a.Error(def.Span(), "default value must be a literal")
+		return
+	}
+	a.handleExpr(scope, def)
+	a.Matches(ty, a.asIntConst(ty, def), def.Span())
+}
+
+func isLiteralExpr(e ast.Expr) bool {
+	switch e.Kind() {
+	case ast.ExprKindNil, ast.ExprKindBool, ast.ExprKindNumber, ast.ExprKindString:
+		return true
+	case ast.ExprKindUnary:
+		unary := e.Unary()
+		return unary.Op == ast.UnaryOpNegate && unary.Value.Kind() == ast.ExprKindNumber
+	default:
+		return false
+	}
+}
+
+// isRaisingFuncPath reports whether expr names a `#[may_raise]` function
+// directly, as opposed to a value that holds it.
+func isRaisingFuncPath(expr ast.Expr) bool {
+	if expr.Kind() != ast.ExprKindPath {
+		return false
+	}
+	sym := expr.Path().ResolvedSymbol
+	if sym == nil || !sym.IsValue() || sym.Value().Kind() != ast.ValFunction {
+		return false
+	}
+	return sym.Value().Function().Def.Attributes.Has("may_raise")
+}
@@ -0,0 +1,414 @@
+package sema
+
+import (
+	"fmt"
+
+	"github.com/gluax-lang/gluax/frontend/ast"
+)
+
+// tuning of the automatic inlining, cost is the number of statements and
+// expressions in a body
+const (
+	inlineLeafMaxCost       = 12  // leaf functions at most this big are always worth it
+	inlineSingleSiteMaxCost = 120 // functions called from one place, bigger ones hurt traces
+	inlineMaxLocals         = 40  // locals a single inlined body may add to its caller
+)
+
+// InlineMode is what the `#[inline]` attribute of a function asks for.
+type InlineMode uint8
+
+const (
+	InlineAuto   InlineMode = iota // no attribute, the cost model decides
+	InlineHint                     // `#[inline]`
+	InlineAlways                   // `#[inline(always)]`
+	InlineNever                    // `#[inline(never)]`
+)
+
+func inlineModeOf(def *ast.Function) InlineMode {
+	attr := def.Attributes.Get("inline")
+	switch {
+	case attr == nil:
+		return InlineAuto
+	case def.Attributes.HasTokenTreeArgs("inline", "always"):
+		return InlineAlways
+	case def.Attributes.HasTokenTreeArgs("inline", "never"):
+		return InlineNever
+	}
+	return InlineHint
+}
+
+func (a *Analysis) checkInlineAttribute(def *ast.Function) {
+	attr := def.Attributes.Get("inline")
+	if attr == nil || attr.IsInputNone() {
+		return
+	}
+	if !attr.IsInputTokenTree() || len(attr.TokenTree) != 1 || inlineModeOf(def) == InlineHint {
+		a.Error(attr.Span, "expected `#[inline]`, `#[inline(always)]` or `#[inline(never)]`")
+	}
+}
+
+// InlineDecision is whether calls to a function get its body pasted in, and why.
+type InlineDecision struct {
+	Inline bool
+	Reason string
+}
+
+type inlineInfo struct {
+	def      *ast.Function
+	name     string
+	cost     int
+	locals   int
+	calls    map[FuncRef]struct{} // gluax functions called from the body
+	sites    int                  // calls to this function in the whole program
+	escapes  bool                 // referenced other than by calling it
+	decision InlineDecision
+}
+
+// InlineSkip is a call that was not inlined even though its callee should be.
+type InlineSkip struct {
+	Callee string
+	Span   Span
+	Reason string
+}
+
+// InlinePolicy holds the inlining decisions for every function of a state,
+// they are made once all bodies are analyzed since they depend on call sites.
+type InlinePolicy struct {
+	infos map[FuncRef]*inlineInfo
+	order []FuncRef // definition order, for reports
+	skips []InlineSkip
+}
+
+// InlinePolicy returns the inlining decisions of state, computing them on
+// first use.
+func (pa *ProjectAnalysis) InlinePolicy(state *State) *InlinePolicy {
+	if state.Inlining == nil {
+		state.Inlining = newInlinePolicy(state)
+	}
+	return state.Inlining
+}
+
+func newInlinePolicy(state *State) *InlinePolicy {
+	p := &InlinePolicy{infos: make(map[FuncRef]*inlineInfo)}
+
+	var roots, metamethods []*ast.Function
+	var lets []*ast.Let
+	for _, path := range sortedFilePaths(state.Files) {
+		file := state.Files[path]
+		if file.Ast == nil {
+			continue
+		}
+		for _, f := range file.Ast.Funcs {
+			if f.Cfg.Active() {
+				roots = append(roots, f)
+			}
+		}
+		for _, impl := range file.Ast.ImplClasses {
+			if !impl.Cfg.Active() {
+				continue
+			}
+			for i := range impl.Methods {
+				if !impl.Methods[i].Cfg.Active() {
+					continue
+				}
+				roots = append(roots, &impl.Methods[i])
+				metamethods = append(metamethods, &impl.Methods[i])
+			}
+		}
+		for _, impl := range file.Ast.ImplTraits {
+			if !impl.Cfg.Active() {
+				continue
+			}
+			for i := range impl.Methods {
+				if impl.Methods[i].Cfg.Active() {
+					roots = append(roots, &impl.Methods[i])
+				}
+			}
+		}
+		for _, let := range file.Ast.Lets {
+			if let.Cfg.Active() {
+				lets = append(lets, let)
+			}
+		}
+	}
+
+	for _, def := range roots {
+		if def.Body == nil || def.Name == nil {
+			continue
+		}
+		ref := FuncRef(def.Span().ID)
+		p.infos[ref] = &inlineInfo{def: def, name: def.Name.Raw, calls: make(map[FuncRef]struct{})}
+		p.order = append(p.order, ref)
+	}
+
+	for _, def := range roots {
+		if info := p.infos[FuncRef(def.Span().ID)]; info != nil {
+			info.locals = len(def.Params)
+			p.scan(def.Body, info)
+			if def.Attributes.Has("export", "hook") {
+				info.escapes = true
+			}
+		}
+	}
+	// lua calls metamethods itself, like `__tostring`
+	for _, def := range metamethods {
+		if info := p.infos[FuncRef(def.Span().ID)]; info != nil && isMetamethod(def.Name.Raw) {
+			info.escapes = true
+		}
+	}
+	for _, let := range lets {
+		var visit func(node any) bool
+		visit = func(node any) bool { return p.visit(node, nil, false, visit) }
+		for i := range let.Values {
+			ast.WalkExpr(&let.Values[i], visit)
+		}
+	}
+
+	for _, ref := range p.order {
+		info := p.infos[ref]
+		info.decision = p.decide(ref, info)
+	}
+	return p
+}
+
+// scan collects the cost, locals and calls of a body, bodies of nested
+// functions add to the cost but they get their own locals in lua.
+func (p *InlinePolicy) scan(body *ast.Block, info *inlineInfo) {
+	var visit func(node any) bool
+	nested := 0
+	visit = func(node any) bool {
+		if e, ok := node.(*ast.Expr); ok && e.Kind() == ast.ExprKindFunction {
+			info.cost++
+			if body := e.Function().Body; body != nil {
+				nested++
+				ast.WalkBlock(body, visit)
+				nested--
+			}
+			return false
+		}
+		return p.visit(node, info, nested > 0, visit)
+	}
+	ast.WalkBlock(body, visit)
+}
+
+// visit accounts node to info, which is nil outside of function bodies. walk
+// is used for the children visit has to walk itself.
+func (p *InlinePolicy) visit(node any, info *inlineInfo, nested bool, walk func(node any) bool) bool {
+	addLocals := func(n int) {
+		if info != nil && !nested {
+			info.locals += n
+		}
+	}
+	if info != nil {
+		info.cost++
+	}
+	switch node := node.(type) {
+	case *ast.Let:
+		addLocals(len(node.Names))
+	case *ast.Expr:
+		switch node.Kind() {
+		case ast.ExprKindForNum:
+			addLocals(1)
+		case ast.ExprKindForIn:
+			forIn := node.ForIn()
+			addLocals(len(forIn.Vars))
+			p.called(forIn.RangeMethod, info)
+			p.called(forIn.BoundMethod, info)
+			p.called(forIn.PairsMethod, info)
+		case ast.ExprKindPath:
+			if target := p.pathTarget(node); target != nil {
+				target.escapes = true
+			}
+		case ast.ExprKindPostfix:
+			postfix := node.Postfix()
+			switch op := postfix.Op.(type) {
+			case *ast.Call:
+				if op.Catch != nil {
+					addLocals(1)
+				}
+				p.called(op.SemaFunc, info)
+				if op.Method == nil && p.pathTarget(&postfix.Left) != nil {
+					// the callee itself is not an escaping reference
+					if info != nil {
+						info.cost++
+					}
+					walkArgs(op, walk)
+					return false
+				}
+			case *ast.DotAccess:
+				if op.Method != nil {
+					if target := p.infos[FuncRefOf(op.Method)]; target != nil {
+						target.escapes = true
+					}
+				}
+			}
+		}
+	}
+	return true
+}
+
+// walkArgs walks what is left of a call after skipping its callee.
+func walkArgs(call *ast.Call, visit func(node any) bool) {
+	for i := range call.Args {
+		ast.WalkExpr(&call.Args[i], visit)
+	}
+	for i := range call.NamedArgs {
+		ast.WalkExpr(&call.NamedArgs[i].Value, visit)
+	}
+	if call.Catch != nil {
+		ast.WalkBlock(&call.Catch.Block, visit)
+	}
+}
+
+func (p *InlinePolicy) pathTarget(e *ast.Expr) *inlineInfo {
+	if e.Kind() != ast.ExprKindPath {
+		return nil
+	}
+	sym := e.Path().ResolvedSymbol
+	if sym == nil || !sym.IsValue() || sym.Value().Kind() != ast.ValFunction {
+		return nil
+	}
+	return p.infoOf(sym.Value().Function())
+}
+
+// infoOf returns the info of f, bound method values are body-less copies of
+// their method that share its span, they are never inlined.
+func (p *InlinePolicy) infoOf(f *ast.SemFunction) *inlineInfo {
+	if f == nil || f.Def.Body == nil {
+		return nil
+	}
+	return p.infos[FuncRefOf(f)]
+}
+
+func (p *InlinePolicy) called(f *ast.SemFunction, info *inlineInfo) {
+	if f == nil || f.Def.Body == nil {
+		return
+	}
+	ref := FuncRefOf(f)
+	target := p.infos[ref]
+	if target == nil {
+		return
+	}
+	target.sites++
+	if info != nil {
+		info.calls[ref] = struct{}{}
+	}
+}
+
+// recursive reports whether ref can end up calling itself.
+func (p *InlinePolicy) recursive(ref FuncRef) bool {
+	seen := make(map[FuncRef]struct{})
+	work := []FuncRef{ref}
+	for len(work) > 0 {
+		cur := work[len(work)-1]
+		work = work[:len(work)-1]
+		info := p.infos[cur]
+		if info == nil {
+			continue
+		}
+		for callee := range info.calls {
+			if callee == ref {
+				return true
+			}
+			if _, ok := seen[callee]; !ok {
+				seen[callee] = struct{}{}
+				work = append(work, callee)
+			}
+		}
+	}
+	return false
+}
+
+func (p *InlinePolicy) decide(ref FuncRef, info *inlineInfo) InlineDecision {
+	def := info.def
+	no := func(format string, args ...any) InlineDecision {
+		return InlineDecision{Reason: fmt.Sprintf(format, args...)}
+	}
+	yes := func(format string, args ...any) InlineDecision {
+		return InlineDecision{Inline: true, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	mode := inlineModeOf(def)
+	switch {
+	case def.IsGlobal():
+		return no("global functions are defined by lua")
+	case hasVarargs(def):
+		return no("has varargs")
+	case mode == InlineNever:
+		return no("marked `#[inline(never)]`")
+	case p.recursive(ref):
+		return no("is recursive")
+	case info.locals > inlineMaxLocals:
+		return no("declares %d locals, more than %d", info.locals, inlineMaxLocals)
+	case info.sites == 0 && info.escapes:
+		return no("only called from lua")
+	case info.sites == 0:
+		return no("not called from gluax code")
+	case mode == InlineAlways:
+		return yes("marked `#[inline(always)]`")
+	case mode == InlineHint:
+		return yes("marked `#[inline]`")
+	case len(info.calls) == 0 && info.cost <= inlineLeafMaxCost:
+		return yes("small leaf function, cost %d", info.cost)
+	case info.sites == 1 && !info.escapes && info.cost <= inlineSingleSiteMaxCost:
+		return yes("only called once, cost %d", info.cost)
+	}
+	return no("cost %d with %d call sites", info.cost, info.sites)
+}
+
+func hasVarargs(def *ast.Function) bool {
+	for _, param := range def.Params {
+		if ast.IsVararg(param.Type) {
+			return true
+		}
+	}
+	return def.ReturnType != nil && ast.IsVararg(*def.ReturnType)
+}
+
+// Decision returns the inlining decision for calls to f.
+func (p *InlinePolicy) Decision(f *ast.SemFunction) InlineDecision {
+	if info := p.infoOf(f); info != nil {
+		return info.decision
+	}
+	return InlineDecision{Reason: "not a function with a body"}
+}
+
+// Locals is how many locals inlining f adds to the caller.
+func (p *InlinePolicy) Locals(f *ast.SemFunction) int {
+	if info := p.infoOf(f); info != nil {
+		return info.locals
+	}
+	return 0
+}
+
+// Skip records a call that was not inlined for a reason only known while
+// generating the caller.
+func (p *InlinePolicy) Skip(f *ast.SemFunction, span Span, reason string) {
+	p.skips = append(p.skips, InlineSkip{Callee: f.Def.Name.Raw, Span: span, Reason: reason})
+}
+
+// InlineReportEntry is a line of `--inline-report`.
+type InlineReportEntry struct {
+	Name   string
+	Span   Span
+	Inline bool
+	Reason string
+	Sites  int
+}
+
+// Report lists the decision of every function in definition order, followed
+// by the calls that were skipped.
+func (p *InlinePolicy) Report() ([]InlineReportEntry, []InlineSkip) {
+	entries := make([]InlineReportEntry, 0, len(p.order))
+	for _, ref := range p.order {
+		info := p.infos[ref]
+		entries = append(entries, InlineReportEntry{
+			Name:   info.name,
+			Span:   info.def.Span(),
+			Inline: info.decision.Inline,
+			Reason: info.decision.Reason,
+			Sites:  info.sites,
+		})
+	}
+	return entries, p.skips
+}
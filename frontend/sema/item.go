@@ -4,6 +4,7 @@ import (
 	"slices"
 
 	"github.com/gluax-lang/gluax/frontend/ast"
+	"github.com/gluax-lang/gluax/frontend/lexer"
 )
 
 func checkPairsIterFunc(a *Analysis, fun *SemFunction) {
@@ -179,3 +180,44 @@ func traitImplements(trait *ast.SemTrait, target *ast.SemTrait) bool {
 	}
 	return false
 }
+
+// traitInstance returns the instance of a generic trait for args, instances
+// are shared so traits can still be compared by pointer.
+func (a *Analysis) traitInstance(trait *ast.SemTrait, args []Type, span Span) *ast.SemTrait {
+	params := trait.Def.Generics.Params
+	if len(args) != len(params) {
+		a.panicf(span, "trait `%s` expects %d type arguments, got %d", trait.Def.Name.Raw, len(params), len(args))
+	}
+	for _, arg := range args {
+		if !isValidAsGenericTypeArgument(arg) {
+			a.panicf(span, "type `%s` cannot be used as a generic type", arg.String())
+		}
+	}
+	for _, inst := range trait.Instances {
+		if slices.EqualFunc(inst.Args, args, a.MatchTypesStrict) {
+			return inst
+		}
+	}
+
+	inst := ast.NewSemTrait(trait.Def)
+	inst.Scope = trait.Scope
+	inst.SuperTraits = trait.SuperTraits
+	inst.Generic = trait
+	inst.Args = args
+	trait.Instances = append(trait.Instances, &inst)
+
+	scope := a.setupTypeGenerics(trait.Scope.(*Scope), trait.Def.Generics, args)
+	SelfScope := scope.Child(false)
+	SelfGeneric := ast.NewSemGenericType(lexer.NewTokIdent("Self", trait.Def.Name.Span()), append([]*ast.SemTrait{&inst}, inst.SuperTraits...), true)
+	SelfScope.ForceAddType("Self", SelfGeneric)
+	for _, method := range trait.Def.Methods {
+		if !method.Cfg.Active() {
+			continue
+		}
+		funcTy := a.handleFunctionSignature(SelfScope, &method)
+		funcTy.Scope = scope
+		funcTy.Trait = &inst
+		inst.Methods[method.Name.Raw] = funcTy
+	}
+	return &inst
+}
@@ -17,9 +17,15 @@ func (a *Analysis) handleLet(scope *Scope, it *ast.Let) {
 		// If an explicit type is given, match & use it
 		if len(it.Types) != 0 && it.Types[i] != nil {
 			explicitTy := a.resolveType(scope, *it.Types[i])
+			if len(it.Values) == lhsCount {
+				ty = a.asIntConst(explicitTy, &it.Values[i])
+			}
 			a.Matches(explicitTy, ty, exprSpan)
 			ty = explicitTy
 		} else {
+			if ty.IsInt() {
+				ty = a.numberType() // bindings are only ints when annotated as such
+			}
 			// Provide inlay hint if no explicit type is given
 			a.InlayHintType(ty.String(), ident.Span())
 		}
@@ -0,0 +1,140 @@
+package sema
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gluax-lang/gluax/frontend/ast"
+)
+
+// maxSafeInteger is the largest integer a lua number (a double) can hold exactly.
+const maxSafeInteger = 1 << 53
+
+// isIntegerLiteral reports whether a number literal is written as an integer,
+// hex literals always are, decimal ones must not have a fraction or an exponent.
+func isIntegerLiteral(raw string) bool {
+	lower := strings.ToLower(raw)
+	if strings.HasPrefix(lower, "0x") {
+		return true
+	}
+	return !strings.ContainsAny(lower, ".e")
+}
+
+// handleNumberLiteral checks that integer literals are exact, literals are
+// `number`s, they only become `int`s where an int is asked for, see asIntConst.
+func (a *Analysis) handleNumberLiteral(expr *ast.Expr) Type {
+	raw := expr.Number().Raw
+	if !isIntegerLiteral(raw) {
+		return a.numberType()
+	}
+	value, err := strconv.ParseInt(raw, 0, 64)
+	if err != nil || value > maxSafeInteger {
+		a.Errorf(expr.Span(), "integer literal `%s` cannot be represented exactly, the largest safe integer is 2^53", raw)
+	}
+	return a.numberType()
+}
+
+// isIntConst reports whether expr is a constant integer, not written as a float.
+func isIntConst(expr *ast.Expr) bool {
+	c := expr.Const
+	if c == nil || c.Kind != ast.ConstNumber || c.Number != math.Trunc(c.Number) {
+		return false
+	}
+	return expr.Kind() != ast.ExprKindNumber || isIntegerLiteral(expr.Number().Raw)
+}
+
+// isIntExpr reports whether expr is an `int` or can be used as one.
+func isIntExpr(expr *ast.Expr) bool {
+	return expr.Type().IsInt() || isIntConst(expr)
+}
+
+// asIntConst returns the type of expr, turning constant integers into `int`s
+// when want is an int.
+func (a *Analysis) asIntConst(want Type, expr *ast.Expr) Type {
+	ty := expr.Type()
+	if want.IsTuple() && expr.Kind() == ast.ExprKindTuple {
+		values, wants := expr.Tuple().Values, want.Tuple().Elems
+		if len(values) != len(wants) {
+			return ty
+		}
+		elems := make([]Type, len(values))
+		for i := range values {
+			elems[i] = a.asIntConst(wants[i], &values[i])
+		}
+		ty = ast.NewSemType(ast.SemTuple{Elems: elems}, expr.Span())
+		expr.SetType(ty)
+		return ty
+	}
+	if want.IsVec() && expr.Kind() == ast.ExprKindVecInit && len(expr.VecInit().Generics) == 0 {
+		// `vec{1, 2}` is a vec<int> if that's what is asked for
+		elem := want.Class().Generics.Params[0]
+		values := expr.VecInit().Values
+		for i := range values {
+			if !a.asIntConst(elem, &values[i]).IsInt() {
+				return ty
+			}
+		}
+		if len(values) > 0 {
+			ty = a.vecType(a.intType(), expr.Span())
+			expr.SetType(ty)
+		}
+		return ty
+	}
+	switch {
+	case want.IsVararg():
+		want = want.Vararg().Type
+	case want.IsNilable():
+		want = want.NilableInnerType()
+	}
+	if want.IsInt() && !ty.IsInt() && isIntConst(expr) {
+		ty = a.intType()
+		expr.SetType(ty)
+	}
+	return ty
+}
+
+// checkBitOperand validates one side of a bitwise operation, these lower to the
+// LuaJIT `bit` library which truncates its operands to signed 32-bit integers.
+func (a *Analysis) checkBitOperand(expr *ast.Expr) {
+	ty := expr.Type()
+	if !ty.IsNumber() {
+		a.Errorf(expr.Span(), "attempted to perform bitwise operation on non-number value, got: %s", ty.String())
+		return
+	}
+	if c := expr.Const; c != nil && c.Kind == ast.ConstNumber {
+		n := c.Number
+		if n != math.Trunc(n) {
+			a.Errorf(expr.Span(), "bitwise operation on non-integer constant `%s`", c.Lua())
+		} else if n < math.MinInt32 || n > math.MaxUint32 {
+			a.Warningf(expr.Span(), "constant `%s` does not fit in 32 bits and will wrap", c.Lua())
+		}
+		return
+	}
+	if !ty.IsInt() {
+		a.Errorf(expr.Span(), "bitwise operation on `%s` which may be a float, fractions are truncated; use an `int`", ty.String())
+	}
+}
+
+// checkShiftAmount warns about shift amounts that LuaJIT silently masks to 0..31.
+func (a *Analysis) checkShiftAmount(expr *ast.Expr) {
+	c := expr.Const
+	if c == nil || c.Kind != ast.ConstNumber {
+		return
+	}
+	if c.Number < 0 || c.Number > 31 {
+		a.Warningf(expr.Span(), "shift amount `%s` is outside 0..31 and will be masked", c.Lua())
+	}
+}
+
+// blockAsIntConst is asIntConst for the value of a block.
+func (a *Analysis) blockAsIntConst(want Type, block *ast.Block) Type {
+	stmts := block.ActiveStmts()
+	if n := len(stmts); n > 0 {
+		last, ok := stmts[n-1].(*ast.StmtExpr)
+		if ok && !last.HasSemicolon && block.StopAt() == -1 {
+			block.SetType(a.asIntConst(want, &last.Expr))
+		}
+	}
+	return block.Type()
+}
@@ -41,7 +41,7 @@ func resolvePathGeneric[T any](a *Analysis, scope *Scope, path *ast.Path, leafRe
 			if i > 0 && !currentSym.IsPublic() {
 				a.Errorf(seg.Span(), "`%s` is private", seg.Ident.Raw)
 			}
-			if !currentSym.IsType() || !currentSym.Type().IsClass() {
+			if !currentSym.IsTypeAlias() && (!currentSym.IsType() || !currentSym.Type().IsClass()) {
 				checkSegmentGenerics(a, seg)
 			}
 			if currentSym.IsImport() {
@@ -72,13 +72,19 @@ func (a *Analysis) resolvePathType(scope *Scope, path *ast.Path) Type {
 			return nil
 		}
 		sym = getImportScope(sym.Import()).GetSymbol(leaf.Ident.Raw)
-		if sym == nil || !sym.IsType() {
+		if sym == nil || (!sym.IsType() && !sym.IsTypeAlias()) {
 			return nil
 		}
 		if len(path.Segments) > 1 && !sym.IsPublic() {
 			a.Errorf(leaf.Span(), "`%s` is private", leaf.Ident.Raw)
 		}
 
+		if sym.IsTypeAlias() {
+			a.AddRef(*sym, leaf.Span())
+			ty := a.resolveTypeAlias(scope, sym.TypeAlias(), leaf.Generics, leaf.Span())
+			return &ty
+		}
+
 		var ty *Type
 		if sym.IsType() && sym.Type().IsClass() && len(leaf.Generics) > 0 {
 			cls := a.resolveClass(scope, sym.Type().Class(), leaf.Generics, leaf.Span())
@@ -115,16 +121,24 @@ func (a *Analysis) resolvePathValue(scope *Scope, path *ast.Path) *Value {
 			path.ResolvedSymbol = sym
 			a.AddRef(*sym, leaf.Span())
 			return sym.Value()
-		} else if sym.IsType() {
-			baseTy := sym.Type()
+		} else if sym.IsType() || sym.IsTypeAlias() {
+			var typeGenerics []ast.Type
+			if len(path.Segments) >= 2 {
+				prevSeg := path.Segments[len(path.Segments)-2]
+				typeGenerics = prevSeg.Generics
+			}
+
+			var baseTy *Type
+			if sym.IsTypeAlias() {
+				aliasTy := a.resolveTypeAlias(scope, sym.TypeAlias(), typeGenerics, leaf.Span())
+				baseTy = &aliasTy
+				typeGenerics = nil // already applied by the alias
+			} else {
+				baseTy = sym.Type()
+			}
 			var resolvedTy Type
 
 			if baseTy.IsClass() {
-				var typeGenerics []ast.Type
-				if len(path.Segments) >= 2 {
-					prevSeg := path.Segments[len(path.Segments)-2]
-					typeGenerics = prevSeg.Generics
-				}
 				st := a.resolveClass(scope, baseTy.Class(), typeGenerics, leaf.Span())
 				resolvedTy = ast.NewSemType(st, baseTy.Span())
 			} else {
@@ -190,7 +204,9 @@ func (a *Analysis) resolvePathSymbol(scope *Scope, path *ast.Path) *Symbol {
 		if len(path.Segments) > 1 && !sym.IsPublic() {
 			a.Errorf(leaf.Span(), "`%s` is private", raw)
 		}
-		checkSegmentGenerics(a, leaf)
+		if !sym.IsTrait() {
+			checkSegmentGenerics(a, leaf) // trait arguments are checked by resolvePathTrait
+		}
 		path.ResolvedSymbol = sym
 		a.AddRef(*sym, leaf.Span())
 		return sym
@@ -201,10 +217,37 @@ func (a *Analysis) resolvePathSymbol(scope *Scope, path *ast.Path) *Symbol {
 	return t
 }
 
+// resolvePathTrait resolves a trait, generic traits resolve to the instance
+// for the type arguments of the path.
 func (a *Analysis) resolvePathTrait(scope *Scope, path *ast.Path) *ast.SemTrait {
 	sym := a.resolvePathSymbol(scope, path)
 	if !sym.IsTrait() {
 		a.panicf(path.Span(), "expected trait type")
 	}
-	return sym.Trait()
+	trait := sym.Trait()
+	leaf := path.Segments[len(path.Segments)-1]
+	if len(trait.Def.Generics.Params) == 0 {
+		checkSegmentGenerics(a, leaf)
+		return trait
+	}
+	args := make([]Type, len(leaf.Generics))
+	for i, g := range leaf.Generics {
+		args[i] = a.resolveType(scope, g)
+	}
+	return a.traitInstance(trait, args, path.Span())
+}
+
+// resolveBoundTrait resolves a trait used as a bound or a supertrait, generic
+// traits can only be implemented and named in qualified paths.
+func (a *Analysis) resolveBoundTrait(scope *Scope, path *ast.Path) *ast.SemTrait {
+	sym := a.resolvePathSymbol(scope, path)
+	if !sym.IsTrait() {
+		a.panicf(path.Span(), "expected trait type")
+	}
+	trait := sym.Trait()
+	if len(trait.Def.Generics.Params) > 0 {
+		a.panicf(path.Span(), "generic trait `%s` cannot be used as a bound", trait.Def.Name.Raw)
+	}
+	checkSegmentGenerics(a, path.Segments[len(path.Segments)-1])
+	return trait
 }
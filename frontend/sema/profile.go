@@ -0,0 +1,99 @@
+package sema
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	ProfileDev     = "dev"
+	ProfileRelease = "release"
+)
+
+var macroNameRegex = regexp.MustCompile(`^[A-Za-z_]\w*$`)
+
+// macros the compiler defines itself
+var reservedMacros = []string{"SERVER", "CLIENT", "__LINE__"}
+
+// ParseDefine parses a `NAME` or `NAME=value` command line define.
+func ParseDefine(define string) (name, value string, err error) {
+	name, value, _ = strings.Cut(define, "=")
+	if err := checkMacroName(name); err != nil {
+		return "", "", err
+	}
+	return name, value, nil
+}
+
+func checkMacroName(name string) error {
+	if !macroNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid macro name `%s`", name)
+	}
+	for _, reserved := range reservedMacros {
+		if name == reserved {
+			return fmt.Errorf("`%s` is defined by the compiler", name)
+		}
+	}
+	return nil
+}
+
+// applyProfile picks the profile of the build and computes the macros every
+// file starts with. DEBUG is defined unless the build is a release one,
+// gluax.toml `[defines]` come next, then the ones of the profile and last the
+// ones given on the command line.
+func (pa *ProjectAnalysis) applyProfile() error {
+	name := pa.Options.Profile
+	if name == "" {
+		name = ProfileDev
+		if pa.Options.Release {
+			name = ProfileRelease
+		}
+	}
+	profile, ok := pa.Config.Profiles[name]
+	if !ok && name != ProfileDev && name != ProfileRelease {
+		return fmt.Errorf("unknown profile `%s`, gluax.toml has no [profile.%s]", name, name)
+	}
+	pa.Options.Profile = name
+	switch {
+	case profile.Release != nil:
+		pa.Options.Release = *profile.Release
+	case name == ProfileRelease:
+		pa.Options.Release = true
+	}
+
+	macros := make(map[string]string)
+	if !pa.Options.Release {
+		macros["DEBUG"] = ""
+	}
+	if err := addTomlDefines(macros, pa.Config.Defines, "[defines]"); err != nil {
+		return err
+	}
+	if err := addTomlDefines(macros, profile.Defines, "[profile."+name+".defines]"); err != nil {
+		return err
+	}
+	for define, value := range pa.Options.Defines {
+		if err := checkMacroName(define); err != nil {
+			return err
+		}
+		macros[define] = value
+	}
+	pa.macros = macros
+	return nil
+}
+
+func addTomlDefines(macros map[string]string, defines map[string]any, table string) error {
+	for name, value := range defines {
+		if err := checkMacroName(name); err != nil {
+			return fmt.Errorf("gluax.toml %s: %w", table, err)
+		}
+		switch value := value.(type) {
+		case string:
+			macros[name] = value
+		case int64, float64, bool:
+			macros[name] = fmt.Sprint(value)
+		default:
+			return fmt.Errorf("gluax.toml %s: `%s` must be a string, number or boolean", table, name)
+		}
+	}
+	return nil
+}
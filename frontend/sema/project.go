@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"maps"
 	"os"
 	"path/filepath"
 	"runtime/debug"
@@ -15,8 +16,6 @@ import (
 	"github.com/gluax-lang/gluax/frontend"
 	"github.com/gluax-lang/gluax/frontend/ast"
 	"github.com/gluax-lang/gluax/frontend/lexer"
-	"github.com/gluax-lang/gluax/frontend/parser"
-	"github.com/gluax-lang/gluax/frontend/preprocess"
 	"github.com/gluax-lang/gluax/std"
 	protocol "github.com/gluax-lang/lsp"
 )
@@ -44,6 +43,9 @@ type ProjectAnalysis struct {
 
 	// After merging, final map that combines them
 	files map[string]*Analysis
+
+	// macros of the active profile, every file starts with them
+	macros map[string]string
 }
 
 // NewProjectAnalysis builds a project-level container.
@@ -83,9 +85,13 @@ func (pa *ProjectAnalysis) getStateFiles() map[string]*Analysis {
 	return pa.currentState.Files
 }
 
+func (pa *ProjectAnalysis) isBuiltinTypesFile(path string) bool {
+	return pa.Config.Std && strings.Contains(path, typesFile)
+}
+
 func (pa *ProjectAnalysis) newAnalysis(path string) *Analysis {
 	scope := pa.currentState.RootScope
-	if !pa.Config.Std || !strings.Contains(path, typesFile) {
+	if !pa.isBuiltinTypesFile(path) {
 		scope = pa.currentState.RootScope.Child(false)
 	}
 	return &Analysis{
@@ -107,32 +113,28 @@ func (pa *ProjectAnalysis) parseFile(path string) (*Analysis, error) {
 		return analysis, fmt.Errorf("failed to load file: %w", err)
 	}
 
-	macros := map[string]string{
-		pa.currentState.Label: "",
-	}
-	preprocessed, diag := preprocess.Preprocess(code, macros)
-	if diag != nil {
-		analysis.Diags = append(analysis.Diags, *diag)
-		return analysis, fmt.Errorf("preprocessing failed")
+	macros := maps.Clone(pa.macros)
+	if macros == nil {
+		macros = make(map[string]string, 1)
 	}
-
-	toks, diag := lexer.Lex(path, preprocessed)
-	if diag != nil {
-		analysis.Diags = append(analysis.Diags, *diag)
-		return analysis, fmt.Errorf("lexing failed")
+	macros[pa.currentState.Label] = ""
+	file := pa.loadFile(path, code, macros)
+	analysis.Diags = append(analysis.Diags, file.warnings...)
+	if file.diag != nil {
+		analysis.Diags = append(analysis.Diags, *file.diag)
 	}
-
-	astRoot, errors, hardErr := parser.Parse(toks)
-	if hardErr {
-		analysis.Diags = append(analysis.Diags, errors...)
-		return analysis, fmt.Errorf("parsing failed")
+	analysis.Diags = append(analysis.Diags, file.diags...)
+	if file.err != nil {
+		return analysis, file.err
 	}
 
-	if len(errors) > 0 {
-		analysis.Diags = append(analysis.Diags, errors...)
+	astRoot := file.ast
+	if pa.Options.Cache != nil {
+		astRoot = astRoot.Clone()
 	}
-
 	analysis.Ast = astRoot
+	analysis.Code = file.code
+	analysis.resolveItemCfgs()
 	return analysis, nil
 }
 
@@ -167,11 +169,15 @@ func (pa *ProjectAnalysis) AnalyzeFromEntryPoint(entryPointPath string) error {
 
 		// add this file's imports to the queue to be parsed.
 		for _, imp := range analysis.Ast.Imports {
+			if !imp.Cfg.Active() {
+				continue
+			}
 			resolvedPath, resolveErr := analysis.resolveImportPath(analysis.Src, imp.Path.Raw)
 			if resolveErr != nil {
 				analysis.Errorf(imp.Path.Span(), "import error: %v", resolveErr)
 				continue
 			}
+			analysis.Deps = append(analysis.Deps, resolvedPath)
 			queue = append(queue, resolvedPath)
 		}
 	}
@@ -197,6 +203,9 @@ func (pa *ProjectAnalysis) AnalyzeFromEntryPoint(entryPointPath string) error {
 
 	runPhase(func(a *Analysis) {
 		for _, imp := range a.Ast.Imports {
+			if !imp.Cfg.Active() {
+				continue
+			}
 			a.handleImport(a.Scope, imp)
 		}
 	})
@@ -385,6 +394,59 @@ type CompileOptions struct {
 	Workspace    string
 	VirtualFiles map[string]string
 	Release      bool
+	// Profile is the `[profile.<name>]` of gluax.toml to build with, `dev`, or
+	// `release` for release builds, when empty
+	Profile string
+	// Defines are macros from the command line, they win over gluax.toml
+	Defines map[string]string
+	// RewriteErrors embeds the source map into the output, errors raised from
+	// generated code get rewritten to gluax positions at runtime
+	RewriteErrors bool
+	Emit          EmitMode
+	// Split generates a lua file per gluax file, the realm file loads them
+	Split bool
+	// Roots are what release builds keep, the roots of gluax.toml when zero
+	Roots RootKind
+	// Cache, when set, reuses the parsed files of earlier builds
+	Cache *ParseCache
+	// Realms limits the analysis to some realms, the others are left empty.
+	// Zero means all of them.
+	Realms Realm
+}
+
+// Realm is a set of states to analyze.
+type Realm uint8
+
+const (
+	RealmServer Realm = 1 << iota
+	RealmClient
+
+	RealmAll = RealmServer | RealmClient
+)
+
+func (r Realm) Has(realm Realm) bool {
+	return r == 0 || r&realm != 0
+}
+
+// EmitMode is how the generated lua is laid out.
+type EmitMode uint8
+
+const (
+	EmitPretty   EmitMode = iota // indented, with comments
+	EmitCompact                  // no indentation, comments or blank lines
+	EmitMinified                 // compact, with short local names and no spaces
+)
+
+func ParseEmitMode(s string) (EmitMode, error) {
+	switch s {
+	case "", "pretty":
+		return EmitPretty, nil
+	case "compact":
+		return EmitCompact, nil
+	case "minified":
+		return EmitMinified, nil
+	}
+	return EmitPretty, fmt.Errorf("unknown emit mode `%s`, expected pretty, compact or minified", s)
 }
 
 func AnalyzeProject(options CompileOptions) (*ProjectAnalysis, error) {
@@ -400,15 +462,22 @@ func AnalyzeProject(options CompileOptions) (*ProjectAnalysis, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to load project config: %w", err)
 	}
+	if err := pa.applyProfile(); err != nil {
+		return nil, err
+	}
 
 	pa.serverState = NewState("SERVER")
 	pa.clientState = NewState("CLIENT")
 
-	if err := pa.processState(pa.serverState, pa.Workspace()); err != nil {
-		return nil, err
+	if pa.Options.Realms.Has(RealmServer) {
+		if err := pa.processState(pa.serverState, pa.Workspace()); err != nil {
+			return nil, err
+		}
 	}
-	if err := pa.processState(pa.clientState, pa.Workspace()); err != nil {
-		return nil, err
+	if pa.Options.Realms.Has(RealmClient) {
+		if err := pa.processState(pa.clientState, pa.Workspace()); err != nil {
+			return nil, err
+		}
 	}
 
 	// Now unify pa.filesServer and pa.filesClient into pa.files
@@ -0,0 +1,471 @@
+package sema
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gluax-lang/gluax/common"
+	"github.com/gluax-lang/gluax/frontend/ast"
+)
+
+// FuncRef identifies a free function. A SemFunction is created every time the
+// body of a function is analyzed, so the span id of the definition is used.
+type FuncRef uint64
+
+// MethodRef identifies a method of a class instantiation.
+type MethodRef struct {
+	Class *ast.SemClass
+	Name  string
+}
+
+func FuncRefOf(f *ast.SemFunction) FuncRef {
+	return FuncRef(f.Def.Span().ID)
+}
+
+// inlined marks a reference through a call that release builds inline, what
+// the body references is kept alive but the function itself is not.
+type inlined struct {
+	node any
+}
+
+// call is an edge through a call of fn, which turns into an inlined edge if
+// the inline policy decides so, the decisions are only known after analysis.
+type call struct {
+	node any
+	fn   FuncRef
+}
+
+// RefGraph records which items reference which, nodes are FuncRef, MethodRef,
+// *ast.SemClass and *ast.Let. Edges are added while bodies are analyzed, from
+// the item whose body is being analyzed.
+type RefGraph struct {
+	edges  map[any]map[any]struct{}
+	owner  any       // nil outside of item bodies
+	callee *ast.Expr // path being called, the call records the edge instead
+}
+
+func NewRefGraph() *RefGraph {
+	return &RefGraph{edges: make(map[any]map[any]struct{})}
+}
+
+func (g *RefGraph) add(to any) {
+	if g.owner == nil || to == nil || g.owner == to {
+		return
+	}
+	set, ok := g.edges[g.owner]
+	if !ok {
+		set = make(map[any]struct{})
+		g.edges[g.owner] = set
+	}
+	set[to] = struct{}{}
+}
+
+// withRefOwner makes owner the source of the edges recorded until the returned
+// function is called.
+func (a *Analysis) withRefOwner(owner any) func() {
+	g := a.State.Refs
+	old := g.owner
+	g.owner = owner
+	return func() { g.owner = old }
+}
+
+// funcNode returns the node a reference to f keeps alive, nil if generating f
+// is not our business (lua functions, lambdas which are part of their owner).
+func FuncNode(f *ast.SemFunction) any {
+	if f == nil || f.Def.Body == nil {
+		return nil
+	}
+	switch {
+	case f.Trait != nil:
+		// trait methods are dispatched through the impl table, which is
+		// generated with the class
+		if f.Class != nil {
+			return f.Class
+		}
+		return nil
+	case f.Class != nil:
+		return MethodRef{Class: f.Class, Name: f.Def.Name.Raw}
+	case f.Def.IsItem:
+		return FuncRefOf(f)
+	}
+	return nil
+}
+
+// callNode is funcNode for a call of f, which might get inlined.
+func callNode(f *ast.SemFunction) any {
+	node := FuncNode(f)
+	if _, isClass := node.(*ast.SemClass); node == nil || isClass {
+		return node
+	}
+	return call{node: node, fn: FuncRefOf(f)}
+}
+
+// markCallee makes the next recordRefs of expr, the callee of a call, a no-op.
+func (a *Analysis) markCallee(expr *ast.Expr) {
+	if expr.Kind() == ast.ExprKindPath {
+		a.State.Refs.callee = expr
+	}
+}
+
+func (a *Analysis) recordRefs(expr *ast.Expr) {
+	g := a.State.Refs
+	if g.owner == nil {
+		return
+	}
+	switch expr.Kind() {
+	case ast.ExprKindPath:
+		if g.callee == expr {
+			g.callee = nil
+			return
+		}
+		sym := expr.Path().ResolvedSymbol
+		if sym == nil || !sym.IsValue() {
+			return
+		}
+		val := sym.Value()
+		switch val.Kind() {
+		case ast.ValFunction:
+			g.add(FuncNode(val.Function()))
+		case ast.ValVariable:
+			if v := val.Variable(); v.Def.IsItem {
+				g.add(v.Def)
+			}
+		}
+	case ast.ExprKindQPath:
+		g.add(FuncNode(expr.QPath().ResolvedMethod))
+	case ast.ExprKindPostfix:
+		switch op := expr.Postfix().Op.(type) {
+		case *ast.Call:
+			if _, isLet := g.owner.(*ast.Let); isLet {
+				// top level code is never inlined
+				g.add(FuncNode(op.SemaFunc))
+			} else {
+				g.add(callNode(op.SemaFunc))
+			}
+			g.add(FuncNode(op.ErrorConv))
+		case *ast.DotAccess:
+			g.add(FuncNode(op.Method))
+		}
+	case ast.ExprKindClassInit, ast.ExprKindVecInit, ast.ExprKindMapInit:
+		if ty := expr.Type(); ty.IsClass() {
+			g.add(ty.Class())
+		}
+	case ast.ExprKindForIn:
+		forIn := expr.ForIn()
+		g.add(callNode(forIn.RangeMethod))
+		g.add(callNode(forIn.BoundMethod))
+		g.add(callNode(forIn.PairsMethod))
+	}
+}
+
+// isMetamethod reports whether a method is called by lua through the metatable,
+// `__x_` methods are protocols of gluax itself.
+func isMetamethod(name string) bool {
+	return strings.HasPrefix(name, "__") && !strings.HasPrefix(name, "__x_")
+}
+
+// RootKind selects which items reachability starts from.
+type RootKind uint8
+
+const (
+	RootMain    RootKind = 1 << iota // the `main` function
+	RootExports                      // items marked `#[export]`
+	RootHooks                        // functions marked `#[hook = "..."]`
+
+	DefaultRoots = RootMain | RootExports | RootHooks
+)
+
+// ParseRoots parses root names, as in `roots` of gluax.toml.
+func ParseRoots(names []string) (RootKind, error) {
+	var roots RootKind
+	for _, name := range names {
+		switch name {
+		case "main":
+			roots |= RootMain
+		case "exports":
+			roots |= RootExports
+		case "hooks":
+			roots |= RootHooks
+		default:
+			return 0, fmt.Errorf("unknown root `%s`, expected main, exports or hooks", name)
+		}
+	}
+	return roots, nil
+}
+
+// Roots returns the roots of the build, from the options, then gluax.toml.
+func (pa *ProjectAnalysis) Roots() RootKind {
+	if pa.Options.Roots != 0 {
+		return pa.Options.Roots
+	}
+	// gluax.toml is validated when it is loaded
+	if roots, err := ParseRoots(pa.Config.Roots); err == nil && roots != 0 {
+		return roots
+	}
+	return DefaultRoots
+}
+
+func (a *Analysis) checkFuncRootAttributes(f *ast.Function) {
+	if f.Attributes.Has("export") && f.Body == nil {
+		a.Error(f.Span(), "`#[export]` is only allowed on functions with a body")
+	}
+	if attr := f.Attributes.Get("hook"); attr != nil {
+		if f.Body == nil {
+			a.Error(f.Span(), "`#[hook]` is only allowed on functions with a body")
+		} else if !attr.IsInputString() {
+			a.Error(attr.Span, "`#[hook]` needs an event name, like `#[hook = \"Think\"]`")
+		}
+	}
+}
+
+func (a *Analysis) checkLetRootAttributes(let *ast.Let) {
+	if let.Attributes.Has("hook") {
+		a.Error(let.Span(), "`#[hook]` is only allowed on functions")
+	}
+	if !let.Attributes.Has("export") {
+		return
+	}
+	if let.IsConst {
+		a.Error(let.Span(), "`#[export]` is not allowed on consts, they are folded away")
+	} else if name := let.Attributes.GetString("export"); name != nil && len(let.Names) != 1 {
+		a.Error(let.Span(), "`#[export = \"...\"]` can only rename a single variable")
+	}
+}
+
+// Reachability is the set of nodes reachable from a set of roots.
+type Reachability struct {
+	nodes map[any]struct{}
+}
+
+func (r *Reachability) Has(node any) bool {
+	_, ok := r.nodes[node]
+	return ok
+}
+
+// Add marks node as reachable, for code generation that turns out to need
+// more than expected.
+func (r *Reachability) Add(node any) {
+	r.nodes[node] = struct{}{}
+}
+
+// Reachable walks the reference graph of state from roots. Method bodies are
+// analyzed again for the class they are reached with, so references that only
+// exist in a concrete instantiation of a generic class are followed too.
+func (pa *ProjectAnalysis) Reachable(state *State, roots RootKind) *Reachability {
+	r := &Reachability{nodes: make(map[any]struct{})}
+	a := pa.anyAnalysis(state)
+	if a == nil {
+		return r
+	}
+
+	policy := pa.InlinePolicy(state)
+	var work []any
+	push := func(node any) {
+		if c, ok := node.(call); ok {
+			if policy.infos[c.fn] != nil && policy.infos[c.fn].decision.Inline {
+				node = inlined{c.node}
+			} else {
+				node = c.node
+			}
+		}
+		if node == nil {
+			return
+		}
+		if _, seen := r.nodes[node]; seen {
+			return
+		}
+		r.nodes[node] = struct{}{}
+		work = append(work, node)
+	}
+
+	if roots&RootMain != 0 && state.MainFunc != nil {
+		push(FuncRefOf(state.MainFunc))
+	}
+	for _, path := range sortedFilePaths(state.Files) {
+		file := state.Files[path]
+		if file.Ast == nil {
+			continue
+		}
+		for _, f := range file.Ast.Funcs {
+			if !f.Cfg.Active() {
+				continue
+			}
+			if (roots&RootExports != 0 && f.Attributes.Has("export")) ||
+				(roots&RootHooks != 0 && f.Attributes.Has("hook")) {
+				push(FuncRef(f.Span().ID))
+			}
+		}
+		if roots&RootExports != 0 {
+			for _, let := range file.Ast.Lets {
+				if !let.Cfg.Active() {
+					continue
+				}
+				if let.Attributes.Has("export") {
+					push(let)
+				}
+			}
+		}
+	}
+
+	for len(work) > 0 {
+		node := work[len(work)-1]
+		work = work[:len(work)-1]
+
+		if in, ok := node.(inlined); ok {
+			// the body is pasted into the caller, only follow what it references
+			node = in.node
+		}
+		if m, ok := node.(MethodRef); ok {
+			push(m.Class)
+		}
+
+		switch node := node.(type) {
+		case MethodRef:
+			if method := a.FindAllClassMethods(node.Class)[node.Name]; method != nil && method.Def.Body != nil {
+				restore := a.withRefOwner(node)
+				a.HandleClassMethod(node.Class, method, true)
+				restore()
+			}
+		case *ast.SemClass:
+			a.expandClass(node, push)
+		}
+
+		for to := range state.Refs.edges[node] {
+			push(to)
+		}
+	}
+
+	return r
+}
+
+// expandClass pushes what a class keeps alive just by existing: its super
+// class, trait impls, metamethods which lua calls for us and the methods of
+// global classes, which the engine calls. `__x_` methods are compiler hooks
+// and are referenced where they are used.
+func (a *Analysis) expandClass(cls *ast.SemClass, push func(any)) {
+	if cls.Super != nil {
+		push(cls.Super)
+	}
+	if !cls.IsFullyConcrete() {
+		return
+	}
+	for name, method := range a.FindAllClassMethods(cls) {
+		if method.Def.Body == nil {
+			continue
+		}
+		if isMetamethod(name) || cls.IsGlobal() {
+			push(MethodRef{Class: cls, Name: name})
+		}
+	}
+	restore := a.withRefOwner(cls)
+	defer restore()
+	actual := cls.Generics.Params
+	for _, metas := range a.State.TraitsByClass[cls.Def] {
+		for _, meta := range metas {
+			if !a.ValidateTypeParameterConstraints(meta.TypeParameters, actual) {
+				continue
+			}
+			for _, method := range meta.Methods {
+				if method.Def.Body != nil {
+					a.HandleClassMethod(cls, method, true)
+				}
+			}
+			break
+		}
+	}
+}
+
+// UnusedItem is a project item that is not reachable from any root.
+type UnusedItem struct {
+	Kind string // "function", "method" or "let"
+	Name string
+	Span Span
+}
+
+// Unused lists the functions, methods and lets of the project itself (not its
+// dependencies) that are not in reach.
+func (pa *ProjectAnalysis) Unused(state *State, reach *Reachability) []UnusedItem {
+	used := func(node any) bool {
+		return reach.Has(node) || reach.Has(inlined{node})
+	}
+	usedMethods := make(map[*ast.Class]map[string]struct{})
+	for node := range reach.nodes {
+		if in, ok := node.(inlined); ok {
+			node = in.node
+		}
+		if m, ok := node.(MethodRef); ok {
+			if usedMethods[m.Class.Def] == nil {
+				usedMethods[m.Class.Def] = make(map[string]struct{})
+			}
+			usedMethods[m.Class.Def][m.Name] = struct{}{}
+		}
+	}
+
+	var unused []UnusedItem
+	workspace := common.FilePathClean(pa.Workspace()) + "/"
+	for _, path := range sortedFilePaths(state.Files) {
+		// the trailing separator keeps sibling directories like `<workspace>-old` out
+		if !strings.HasPrefix(common.FilePathClean(path), workspace) {
+			continue
+		}
+		file := state.Files[path]
+		if file.Ast == nil {
+			continue
+		}
+		for _, f := range file.Ast.Funcs {
+			if !f.Cfg.Active() || f.Body == nil || f.Name == nil {
+				continue
+			}
+			if !used(FuncRef(f.Span().ID)) {
+				unused = append(unused, UnusedItem{Kind: "function", Name: f.Name.Raw, Span: f.Span()})
+			}
+		}
+		for _, let := range file.Ast.Lets {
+			if !let.Cfg.Active() || let.IsConst || reach.Has(let) {
+				continue // consts are folded, a reference doesn't keep them alive
+			}
+			for _, name := range let.Names {
+				unused = append(unused, UnusedItem{Kind: "let", Name: name.Raw, Span: name.Span()})
+			}
+		}
+		for _, impl := range file.Ast.ImplClasses {
+			if !impl.Cfg.Active() || impl.ClassSema == nil {
+				continue
+			}
+			def := impl.ClassSema.Def
+			for _, method := range impl.Methods {
+				if !method.Cfg.Active() || method.Body == nil || method.Name == nil {
+					continue
+				}
+				if _, ok := usedMethods[def][method.Name.Raw]; !ok {
+					name := def.Name.Raw + "::" + method.Name.Raw
+					unused = append(unused, UnusedItem{Kind: "method", Name: name, Span: method.Span()})
+				}
+			}
+		}
+	}
+	return unused
+}
+
+// anyAnalysis returns an analysis of state to run class method analysis with,
+// the main file if there is one.
+func (pa *ProjectAnalysis) anyAnalysis(state *State) *Analysis {
+	if a, ok := state.Files[pa.Main]; ok {
+		return a
+	}
+	paths := sortedFilePaths(state.Files)
+	if len(paths) == 0 {
+		return nil
+	}
+	return state.Files[paths[0]]
+}
+
+func sortedFilePaths(files map[string]*Analysis) []string {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
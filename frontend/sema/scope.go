@@ -13,6 +13,7 @@ type Scope struct {
 	Symbols  map[string][]*Symbol
 	Func     *ast.SemFunction // the function that this scope is in, if any
 	InLoop   bool
+	InDefer  bool // inside a `defer` body, control flow cannot leave it
 	Labels   map[string]struct{}
 	Span     *Span
 }
@@ -42,6 +43,7 @@ func (s *Scope) Child(copyState bool) *Scope {
 	if copyState {
 		child.Func = s.Func
 		child.InLoop = s.InLoop
+		child.InDefer = s.InDefer
 		child.Labels = maps.Clone(s.Labels)
 	}
 	s.Children = append(s.Children, child)
@@ -70,10 +72,16 @@ func (s *Scope) AddLabel(name string) error {
 }
 
 func (s *Scope) LabelExists(name string) bool {
-	return s.walkScopes(func(scope *Scope) bool {
-		_, ok := scope.Labels[name]
-		return ok
-	})
+	for current := s; current != nil; current = current.Parent {
+		if _, ok := current.Labels[name]; ok {
+			return true
+		}
+		// labels outside of a `defer` body can't be jumped to from inside it
+		if current.InDefer && (current.Parent == nil || !current.Parent.InDefer) {
+			return false
+		}
+	}
+	return false
 }
 
 func (s *Scope) AddSymbol(name string, sym *Symbol) error {
@@ -189,7 +197,7 @@ func (s *Scope) IsTraitInScope(trait *ast.SemTrait) bool {
 	return s.walkScopes(func(scope *Scope) bool {
 		for _, symbolSlice := range scope.Symbols {
 			for _, sym := range symbolSlice {
-				if sym.Kind() == ast.SymTrait && sym.Trait() == trait {
+				if sym.Kind() == ast.SymTrait && sym.Trait() == trait.Origin() {
 					return true
 				}
 			}
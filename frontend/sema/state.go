@@ -1,6 +1,7 @@
 package sema
 
 import (
+	"maps"
 	"slices"
 
 	"github.com/gluax-lang/gluax/frontend/ast"
@@ -36,6 +37,12 @@ type State struct {
 
 	DeclRefs []DeclWithRef
 
+	Refs *RefGraph // which items reference which, for dead code elimination
+	// methods used as bound values, which lua code can end up calling
+	BoundMethods map[FuncRef]struct{}
+	// inlining decisions, made on first use after analysis
+	Inlining *InlinePolicy
+
 	MainFunc *ast.SemFunction // The main function of the program, if any
 }
 
@@ -47,6 +54,8 @@ func NewState(label string) *State {
 		Files:          make(map[string]*Analysis),
 		MethodsByClass: make(map[*ast.Class]map[string][]*ClassMethodEntry),
 		TraitsByClass:  make(map[*ast.Class]map[*ast.SemTrait][]*ClassTraitsMeta),
+		Refs:           NewRefGraph(),
+		BoundMethods:   make(map[FuncRef]struct{}),
 	}
 }
 
@@ -153,7 +162,7 @@ func (a *Analysis) CheckConflictingTraitImplementations() {
 					if a.TypeParametersConflict(t1.TypeParameters, t2.TypeParameters) {
 						if t2.Span.Source == a.Src {
 							a.Errorf(t2.Span,
-								"duplicate trait impl for `%s`", tr.Def.Name.Raw)
+								"duplicate trait impl for `%s`", tr.String())
 						}
 						break // one is enough
 					}
@@ -314,7 +323,9 @@ func (a *Analysis) FindAllClassMethods(st *ast.SemClass) map[string]*SemFunction
 	result := make(map[string]*SemFunction)
 
 	methodsByName := a.State.MethodsByClass[st.Def]
-	for name, list := range methodsByName {
+	// handling a method can instantiate classes, keep the order stable
+	for _, name := range slices.Sorted(maps.Keys(methodsByName)) {
+		list := methodsByName[name]
 		for _, meta := range list {
 			if !a.ValidateTypeParameterConstraints(meta.TypeParameters, actual) {
 				continue
@@ -36,6 +36,10 @@ func (a *Analysis) handleStmt(scope *Scope, raw ast.Stmt) (Type, FlowStatus) {
 		unreachable := ast.NewSemType(ast.SemUnreachable{}, stmt.Span())
 		return unreachable, FlowJump
 
+	case *ast.StmtDefer:
+		a.handleDefer(scope, stmt)
+		return a.nilType(), FlowNormal
+
 	case *ast.StmtExpr:
 		res := a.handleExprWithFlow(scope, &stmt.Expr)
 		return stmt.Expr.Type(), res.Flow
@@ -54,6 +58,10 @@ func (a *Analysis) handleReturn(scope *Scope, stmt *ast.StmtReturn) {
 		a.panic(stmt.Span(), "return statement outside of function")
 	}
 
+	if scope.InDefer {
+		a.Error(stmt.Span(), "cannot return from a `defer` block")
+	}
+
 	stmt.IsFuncErroable = scope.IsFuncErrorable()
 
 	getReturnTypes := func() Type {
@@ -64,7 +72,10 @@ func (a *Analysis) handleReturn(scope *Scope, stmt *ast.StmtReturn) {
 			a.handleExpr(scope, expr)
 			exprTy := expr.Type()
 			if len(exprs) == 1 && i == 0 {
-				return exprTy
+				return a.asIntConst(scope.Func.Return, expr)
+			}
+			if ret := scope.Func.Return; ret.IsTuple() && i < len(ret.Tuple().Elems) {
+				exprTy = a.asIntConst(ret.Tuple().Elems[i], expr)
 			}
 			retTys = append(retTys, exprTy)
 		}
@@ -80,15 +91,43 @@ func (a *Analysis) handleReturn(scope *Scope, stmt *ast.StmtReturn) {
 func (a *Analysis) handleThrow(scope *Scope, stmt *ast.StmtThrow) {
 	if !scope.IsFuncErrorable() {
 		a.Error(stmt.Span(), "throw is not allowed outside of an erroable function")
+	} else if scope.InDefer {
+		a.Error(stmt.Span(), "cannot throw from a `defer` block")
 	}
 	a.handleExpr(scope, &stmt.Value)
-	value := stmt.Value.Type()
-	a.Matches(a.stringType(), value, stmt.Value.Span())
+	errTy := a.stringType()
+	if scope.IsFuncErrorable() {
+		errTy = scope.Func.Error
+	}
+	a.Matches(errTy, a.asIntConst(errTy, &stmt.Value), stmt.Value.Span())
+}
+
+func (a *Analysis) handleDefer(scope *Scope, stmt *ast.StmtDefer) {
+	if scope.Func == nil {
+		a.panic(stmt.Span(), "defer statement outside of function")
+	}
+	if scope.Func.HasVarargReturn() {
+		a.Error(stmt.Span(), "defer is not allowed in a function returning varargs")
+	}
+
+	// the body runs on every exit path of the enclosing block, so it must not
+	// jump anywhere itself; loops inside of it start with a fresh label set
+	child := scope.Child(true)
+	child.InDefer = true
+	child.InLoop = false
+	child.Labels = make(map[string]struct{})
+
+	a.handleBlock(child, &stmt.Body)
+	a.Matches(a.nilType(), stmt.Body.Type(), stmt.Body.Span())
 }
 
 func (a *Analysis) handleBreak(scope *Scope, stmt *ast.StmtBreak) {
 	if !scope.InLoop {
-		a.Error(stmt.Span(), "break is not allowed outside of a loop")
+		if scope.InDefer {
+			a.Error(stmt.Span(), "cannot break out of a `defer` block")
+		} else {
+			a.Error(stmt.Span(), "break is not allowed outside of a loop")
+		}
 	}
 	if stmt.Label != nil {
 		if !scope.LabelExists(stmt.Label.Raw) {
@@ -99,7 +138,11 @@ func (a *Analysis) handleBreak(scope *Scope, stmt *ast.StmtBreak) {
 
 func (a *Analysis) handleContinue(scope *Scope, stmt *ast.StmtContinue) {
 	if !scope.InLoop {
-		a.Error(stmt.Span(), "continue is not allowed outside of a loop")
+		if scope.InDefer {
+			a.Error(stmt.Span(), "cannot continue out of a `defer` block")
+		} else {
+			a.Error(stmt.Span(), "continue is not allowed outside of a loop")
+		}
 	}
 	if stmt.Label != nil {
 		if !scope.LabelExists(stmt.Label.Raw) {
@@ -114,6 +157,11 @@ func (a *Analysis) handleAssignment(scope *Scope, stmt *ast.StmtAssignment) {
 	for i := range stmt.LhsExprs {
 		expr := &stmt.LhsExprs[i]
 		res := a.handleExprWithFlow(scope, expr)
+		if expr.Kind() == ast.ExprKindPostfix {
+			if dot, ok := expr.Postfix().Op.(*ast.DotAccess); ok && dot.Method != nil {
+				a.Errorf(expr.Span(), "cannot assign to method `%s`", dot.Name.Raw)
+			}
+		}
 		// check is left side is a const or not
 		if res.PathValue != nil {
 			val := res.PathValue
@@ -125,6 +173,10 @@ func (a *Analysis) handleAssignment(scope *Scope, stmt *ast.StmtAssignment) {
 			}
 		}
 		exprTy := expr.Type()
-		a.Matches(exprTy, rhsTypes[i], rhsSpans[i])
+		rhsTy := rhsTypes[i]
+		if len(stmt.RhsExpr) == lhsCount {
+			rhsTy = a.asIntConst(exprTy, &stmt.RhsExpr[i])
+		}
+		a.Matches(exprTy, rhsTy, rhsSpans[i])
 	}
 }
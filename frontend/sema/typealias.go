@@ -0,0 +1,59 @@
+package sema
+
+import (
+	"github.com/gluax-lang/gluax/frontend/ast"
+)
+
+// resolveTypeAlias resolves an alias to the type it stands for, generic
+// arguments are resolved in the scope of the use site.
+func (a *Analysis) resolveTypeAlias(scope *Scope, alias *ast.SemTypeAlias, generics []ast.Type, span Span) Type {
+	def := alias.Def
+	if len(generics) != len(def.Generics.Params) {
+		if len(def.Generics.Params) == 0 {
+			a.panicf(span, "type alias `%s` is not generic but generics were provided", def.Name.Raw)
+		}
+		a.panicf(span, "expected %d generics, got %d", len(def.Generics.Params), len(generics))
+	}
+
+	if alias.Resolving {
+		a.panicf(span, "type alias `%s` cannot refer to itself", def.Name.Raw)
+	}
+
+	aliasScope := alias.Scope.(*Scope).Child(false)
+	for i, g := range def.Generics.Params {
+		aliasScope.ForceAddType(g.Name.Raw, a.resolveType(scope, generics[i]))
+	}
+
+	alias.Resolving = true
+	defer func() { alias.Resolving = false }()
+
+	return a.resolveType(aliasScope, def.Type)
+}
+
+func (a *Analysis) resolveTypeAliases() {
+	for _, aliasDef := range a.Ast.TypeAliases {
+		if !aliasDef.Cfg.Active() || len(aliasDef.Generics.Params) > 0 {
+			continue // checked at every use
+		}
+		sym := a.Scope.GetSymbol(aliasDef.Name.Raw)
+		if sym == nil || !sym.IsTypeAlias() {
+			continue
+		}
+		alias := sym.TypeAlias()
+		ty := a.resolveTypeAlias(a.Scope, alias, nil, aliasDef.Name.Span())
+		alias.Resolved = &ty
+	}
+}
+
+func (a *Analysis) resolveNewtypes() {
+	for _, stDef := range a.Ast.Classes {
+		if !stDef.Cfg.Active() || !stDef.IsNewtype() {
+			continue
+		}
+		ty := a.resolveType(a.Scope, *stDef.Newtype)
+		if ty.IsTuple() || ty.IsVararg() || ty.IsUnreachable() {
+			a.panicf(ty.Span(), "type `%s` cannot be used as a newtype", ty.String())
+		}
+		stDef.Underlying = &ty
+	}
+}
@@ -152,6 +152,9 @@ func (a *Analysis) matchFunction(f *SemFunction, other *SemFunction) bool {
 	if f.Def.Errorable != other.Def.Errorable {
 		return false
 	}
+	if f.Def.Errorable && !a.MatchTypesStrict(f.Error, other.Error) {
+		return false
+	}
 	if len(f.Params) != len(other.Params) {
 		return false
 	}
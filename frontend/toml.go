@@ -10,6 +10,34 @@ type GluaxToml struct {
 	Version string `toml:"version" validate:"required"`
 	Lib     bool   `toml:"lib"`
 	Std     bool   `toml:"std"`
+
+	// Defines are preprocessor macros defined in every profile.
+	Defines  map[string]any     `toml:"defines"`
+	Profiles map[string]Profile `toml:"profile"`
+
+	// Roots are what release builds keep, with everything they reach, all of
+	// them when empty.
+	Roots []string `toml:"roots" validate:"dive,oneof=main exports hooks"`
+
+	Addon Addon `toml:"addon"`
+}
+
+// Addon is the `[addon]` table, it fills addon.json of `--target=addon`
+// builds. The title defaults to the name of the project.
+type Addon struct {
+	Title       string   `toml:"title"`
+	Description string   `toml:"description"`
+	Type        string   `toml:"type" validate:"omitempty,oneof=gamemode map weapon vehicle npc entity tool effects model servercontent"`
+	Tags        []string `toml:"tags" validate:"max=2,dive,oneof=fun roleplay scenic movie realism cartoon water comic build"`
+	// Ignore are globs of files that don't go in the addon
+	Ignore []string `toml:"ignore"`
+}
+
+// Profile is a `[profile.<name>]` table, `dev` and `release` exist even when
+// they aren't in gluax.toml.
+type Profile struct {
+	Release *bool          `toml:"release"`
+	Defines map[string]any `toml:"defines"`
 }
 
 func HandleGluaxToml(tomlContent string) (GluaxToml, error) {